@@ -0,0 +1,112 @@
+package vgrouter
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Breadcrumb is one entry in the trail returned by Breadcrumbs.
+type Breadcrumb struct {
+	Path      string     // path of this crumb, the current path or a prefix of it (without the path prefix)
+	RoutePath string     // route path pattern with params as :param which matches Path exactly
+	Name      string     // route name registered with NameRoute for RoutePath, empty if none
+	Label     string     // text to show, see Breadcrumbs
+	Params    url.Values // route params for Path, combined with the query for the current crumb
+	URL       string     // href for the route with Params, as URLFor builds it
+	Current   bool       // true if Path is the current path
+}
+
+// Breadcrumbs returns the trail of pages leading to the current path, starting at the root.
+// Each prefix of the current path (e.g. "/", "/users", "/users/5" for "/users/5") is matched
+// against the routes in the same way as navigation, and the most specific route which matches it
+// exactly (see RouteMatch.IsSelected) gives its crumb.  Prefixes with no exact match, or which are
+// only matched by a catch-all route (other than the current path), have no page and are skipped.
+// The Label is the "breadcrumb" or else "title" value from the route's RouteMeta, or else the
+// last segment of the path ("Home" for "/").
+func (r *Router) Breadcrumbs() []Breadcrumb {
+
+	cur := path.Clean("/" + r.curPath)
+
+	prefixes := []string{"/"}
+	for i := 1; i < len(cur); i++ {
+		if cur[i] == '/' {
+			prefixes = append(prefixes, cur[:i])
+		}
+	}
+	if cur != "/" {
+		prefixes = append(prefixes, cur)
+	}
+
+	var ret []Breadcrumb
+	for _, p := range prefixes {
+
+		current := p == cur
+		var query url.Values
+		if current {
+			query = r.curQuery
+		}
+
+		var rms []*RouteMatch
+		for _, re := range r.rlist {
+			if m := r.matchRoute(re.mpath, p, query, nil); m != nil {
+				rms = append(rms, m)
+			}
+		}
+		best := mostSpecificExact(rms)
+		if best < 0 {
+			continue
+		}
+		rm := rms[best]
+
+		// a catch-all matches every prefix below it, they only have a page if they are current
+		if !current && strings.HasPrefix(rm.mpath[len(rm.mpath)-1], "/*") {
+			continue
+		}
+
+		u := r.Href(p, query)
+		if mp, q, err := rm.mpath.merge(rm.Params); err == nil {
+			u = r.Href(mp, q)
+		}
+
+		ret = append(ret, Breadcrumb{
+			Path:      p,
+			RoutePath: rm.RoutePath,
+			Name:      r.routeName(rm.RoutePath),
+			Label:     breadcrumbLabel(p, rm.Meta),
+			Params:    rm.Params,
+			URL:       u,
+			Current:   current,
+		})
+	}
+
+	return ret
+}
+
+// routeName returns the name registered with NameRoute for routePath, the first in sort
+// order if there are several, or an empty string if none.
+func (r *Router) routeName(routePath string) string {
+	ret := ""
+	for name, mp := range r.nameMap {
+		if mp.String() == routePath && (ret == "" || name < ret) {
+			ret = name
+		}
+	}
+	return ret
+}
+
+// breadcrumbLabel returns the label for the crumb for path p with the route metadata meta.
+func breadcrumbLabel(p string, meta RouteMeta) string {
+
+	if l := meta["breadcrumb"]; l != "" {
+		return l
+	}
+	if l := meta["title"]; l != "" {
+		return l
+	}
+
+	if p == "/" {
+		return "Home"
+	}
+	return p[strings.LastIndex(p, "/")+1:]
+}
@@ -0,0 +1,71 @@
+package vgrouter
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestBreadcrumbs(t *testing.T) {
+
+	r := New(nil)
+	r.SetPathPrefix("/app")
+	h := RouteHandlerFunc(func(rm *RouteMatch) {})
+	r.MustAddRoute("/", h)
+	r.MustAddRoute("/users", h)
+	r.MustAddRoute("/users/:id", h)
+	r.MustAddRoute("/users/:id/posts/:post", h)
+	r.MustNameRoute("user", "/users/:id")
+	r.MustNameRoute("profile", "/users/:id")
+	r.MustSetRouteMeta("/", RouteMeta{"breadcrumb": "Start", "title": "Welcome"})
+	r.MustSetRouteMeta("/users", RouteMeta{"title": "Users"})
+
+	r.process("/users/5/posts/my post/", url.Values{"q": {"x"}})
+
+	expected := []Breadcrumb{
+		{Path: "/", RoutePath: "/", Label: "Start", URL: "/app/"},
+		{Path: "/users", RoutePath: "/users", Label: "Users", URL: "/app/users"},
+		{Path: "/users/5", RoutePath: "/users/:id", Name: "profile", Label: "5",
+			Params: url.Values{"id": {"5"}}, URL: "/app/users/5"},
+		// "/users/5/posts" has no route
+		{Path: "/users/5/posts/my post", RoutePath: "/users/:id/posts/:post", Label: "my post",
+			Params: url.Values{"id": {"5"}, "post": {"my post"}, "q": {"x"}}, URL: "/app/users/5/posts/my%20post?q=x", Current: true},
+	}
+
+	bcs := r.Breadcrumbs()
+	for i := range bcs {
+		if len(bcs[i].Params) == 0 {
+			bcs[i].Params = nil
+		}
+	}
+	if !reflect.DeepEqual(bcs, expected) {
+		t.Errorf("expected breadcrumbs:\n%#v\ngot:\n%#v", expected, bcs)
+	}
+
+	r.process("/", nil)
+	bcs = r.Breadcrumbs()
+	if len(bcs) != 1 || !bcs[0].Current || bcs[0].Path != "/" {
+		t.Errorf("unexpected breadcrumbs for root: %#v", bcs)
+	}
+
+	// the most specific route gives each crumb, and a catch-all only gives the current one
+	r = New(nil)
+	r.MustAddRoute("/docs/*rest", h)
+	r.MustAddRoute("/docs/:page", h)
+	r.MustAddRoute("/docs/intro", h)
+	r.MustAddRoute("/docs", h)
+	r.process("/docs/intro/x/y", nil)
+	var got []string
+	for _, bc := range r.Breadcrumbs() {
+		got = append(got, bc.Path+" "+bc.RoutePath+" "+bc.URL)
+	}
+	expectedDocs := []string{
+		"/docs /docs /docs",
+		"/docs/intro /docs/intro /docs/intro",
+		"/docs/intro/x/y /docs/*rest /docs/intro/x/y",
+	}
+	if !reflect.DeepEqual(got, expectedDocs) {
+		t.Errorf("expected breadcrumbs %v, got %v", expectedDocs, got)
+	}
+
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/vugu/vgrouter/rgen"
 )
@@ -13,9 +16,57 @@ func main() {
 	packageName := flag.String("p", "", "The full package name to use.  If unspecified auto-detection will be attempted using go.mod")
 	recursive := flag.Bool("r", false, "Specify to recursively process subdirectories")
 	q := flag.Bool("q", false, "Only print information upon error (quiet mode)")
+	register := flag.Bool("register", false, "Generate a RegisterRoutes function which adds the routes to a *vgrouter.Router")
+	output := flag.String("o", rgen.DefaultOutputFile, "The name of the file to write in each directory")
+	force := flag.Bool("force", false, "Overwrite output files even if they were not generated by vgrgen")
+	checkTypes := flag.Bool("check-types", false, "Verify each route's type is declared in the Go source (run vugugen first)")
+	parseGo := flag.Bool("parse", false, "Parse Go source for components and //vgrouter:route directives")
+	consts := flag.Bool("consts", false, "Generate a constant for each route and a URL function for each route with parameters")
+	construct := flag.String("construct", "shared", "How route components are constructed: shared (one instance created at startup), fresh (a new instance for each navigation) or cached (created on first navigation)")
+	config := flag.String("config", "", "JSON configuration file to use instead of "+rgen.ConfigFile+" in each directory")
+	check := flag.Bool("check", false, "Do not write anything, exit with status 1 and print the differences if any generated files are stale")
+	watch := flag.Bool("watch", false, "After generating, keep running and regenerate when files are added, removed, renamed or modified")
+	poll := flag.Bool("poll", false, "With -watch, scan for changes every -interval instead of using file system notifications")
+	interval := flag.Duration("interval", time.Second, "With -watch -poll, how often to scan for changes")
+	debounce := flag.Duration("debounce", 200*time.Millisecond, "With -watch, how long to wait for changes to stop before regenerating")
 
 	flag.Parse()
 
+	// flags given explicitly take precedence over the config file
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	opts := options{
+		packageName: *packageName,
+		recursive:   *recursive,
+		register:    *register,
+		consts:      *consts,
+		output:      *output,
+		force:       *force,
+		checkTypes:  *checkTypes,
+		parseGo:     *parseGo,
+	}
+
+	switch *construct {
+	case "shared":
+		opts.construct = rgen.ConstructShared
+	case "fresh":
+		opts.construct = rgen.ConstructFresh
+	case "cached":
+		opts.construct = rgen.ConstructCached
+	default:
+		log.Fatalf("-construct must be shared, fresh or cached, not %q", *construct)
+	}
+
+	var cfg *rgen.Config
+	if *config != "" {
+		var err error
+		cfg, err = rgen.ReadConfigFile(*config, false)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	args := flag.Args()
 	if len(args) == 0 {
 		args = []string{"."} // default to current dir
@@ -25,6 +76,13 @@ func main() {
 		log.Fatalf("-p is only valid with a single directory, either don't use -p or only specify one dir")
 	}
 
+	if *watch && *check {
+		log.Fatalf("-watch and -check cannot be used together")
+	}
+
+	stale := false
+	var watchers []*watcher
+
 	for _, arg := range args {
 
 		dir, err := filepath.Abs(arg)
@@ -36,15 +94,120 @@ func main() {
 			log.Printf("Processing routes for dir: %s", arg)
 		}
 
-		err = rgen.New().
-			SetDir(dir).
-			SetPackageName(*packageName).
-			SetRecursive(*recursive).
-			Generate()
+		dcfg := cfg
+		if dcfg == nil {
+			dcfg, err = rgen.ReadConfig(dir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if dcfg != nil && !*q {
+				log.Printf("Using configuration: %s", filepath.Join(arg, rgen.ConfigFile))
+			}
+		}
+
+		g := opts.generator(dir, dcfg, explicit)
+
+		if *check {
+			diffs, err := g.Check()
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, d := range diffs {
+				stale = true
+				printDiff(d)
+			}
+			continue
+		}
+
+		err = g.Generate()
 		if err != nil {
 			log.Fatal(err)
 		}
 
+		if *watch {
+			watchers = append(watchers, newWatcher(g, dir, *recursive, *q))
+		}
+
+	}
+
+	if stale {
+		os.Exit(1)
+	}
+
+	if len(watchers) > 0 {
+		for _, w := range watchers[1:] {
+			go w.run(*poll, *interval, *debounce)
+		}
+		watchers[0].run(*poll, *interval, *debounce)
+	}
+
+}
+
+// options are the flags which configure the Generator.
+type options struct {
+	packageName string
+	recursive   bool
+	register    bool
+	consts      bool
+	output      string
+	force       bool
+	checkTypes  bool
+	parseGo     bool
+	construct   rgen.Construct
+}
+
+// setters returns the functions which set each option on a Generator, by flag name.
+func (o options) setters() map[string]func(g *rgen.Generator) {
+	return map[string]func(g *rgen.Generator){
+		"p":           func(g *rgen.Generator) { g.SetPackageName(o.packageName) },
+		"r":           func(g *rgen.Generator) { g.SetRecursive(o.recursive) },
+		"register":    func(g *rgen.Generator) { g.SetRegister(o.register) },
+		"consts":      func(g *rgen.Generator) { g.SetConsts(o.consts) },
+		"o":           func(g *rgen.Generator) { g.SetOutputFile(o.output) },
+		"force":       func(g *rgen.Generator) { g.SetForce(o.force) },
+		"check-types": func(g *rgen.Generator) { g.SetCheckTypes(o.checkTypes) },
+		"parse":       func(g *rgen.Generator) { g.SetParseGo(o.parseGo) },
+		"construct":   func(g *rgen.Generator) { g.SetConstruct(o.construct) },
+	}
+}
+
+// generator returns a Generator for dir with the options, then cfg (if not nil) applied.
+// The options for the flags named in explicit, those given on the command line, are applied
+// again last so they take precedence over the config.
+func (o options) generator(dir string, cfg *rgen.Config, explicit map[string]bool) *rgen.Generator {
+
+	g := rgen.New().SetDir(dir)
+
+	setters := o.setters()
+	for _, set := range setters {
+		set(g)
+	}
+
+	if cfg != nil {
+		cfg.Apply(g)
+		for name := range explicit {
+			if set := setters[name]; set != nil {
+				set(g)
+			}
+		}
 	}
 
+	return g
+}
+
+func printDiff(d rgen.Diff) {
+	switch {
+	case d.Missing:
+		fmt.Printf("missing: %s\n", d.Path)
+	case d.Removed:
+		fmt.Printf("to be removed: %s\n", d.Path)
+	default:
+		fmt.Printf("stale: %s\n", d.Path)
+	}
+	for _, r := range d.AddedRoutes {
+		fmt.Printf("\t+ %s\n", r)
+	}
+	for _, r := range d.RemovedRoutes {
+		fmt.Printf("\t- %s\n", r)
+	}
 }
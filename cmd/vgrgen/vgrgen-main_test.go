@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vugu/vgrouter/rgen"
+)
+
+func TestOptionsGenerator(t *testing.T) {
+
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+	writeFile(t, filepath.Join(tmpDir, "index.vugu"))
+	writeFile(t, filepath.Join(tmpDir, "user", "profile.vugu"))
+
+	cfg := &rgen.Config{Output: "cfg_gen.go", Prefix: "/cfg"}
+	opts := options{
+		packageName: "example.com/flag",
+		recursive:   true,
+		consts:      true,
+		output:      "flag_gen.go",
+		construct:   rgen.ConstructFresh,
+	}
+
+	type tcase struct {
+		explicit []string // flags given on the command line
+		output   string   // expected output file name
+	}
+	for _, tc := range []tcase{
+		{nil, "cfg_gen.go"},
+		{[]string{"p", "r", "consts", "construct"}, "cfg_gen.go"},
+		{[]string{"o"}, "flag_gen.go"},
+	} {
+
+		explicit := make(map[string]bool)
+		for _, name := range tc.explicit {
+			explicit[name] = true
+		}
+
+		files, err := opts.generator(tmpDir, cfg, explicit).Render()
+		must(t, err)
+
+		// all options are applied, with the config taking precedence unless the flag was given
+		if len(files) != 2 || filepath.Base(files[0].Path) != tc.output || filepath.Base(files[1].Path) != tc.output {
+			t.Errorf("%v: expected recursive output to %s, got %v", tc.explicit, tc.output, files)
+			continue
+		}
+		src := string(files[0].Content)
+		for _, s := range []string{`"example.com/flag/user"`, "RouteIndex", `"/cfg"`, "func() vugu.Builder"} {
+			if !strings.Contains(src, s) {
+				t.Errorf("%v: output does not contain %q", tc.explicit, s)
+			}
+		}
+	}
+
+	// without a config the flags are used as they are
+	files, err := opts.generator(tmpDir, nil, nil).Render()
+	must(t, err)
+	if len(files) != 2 || filepath.Base(files[0].Path) != "flag_gen.go" {
+		t.Errorf("unexpected files %v", files)
+	}
+
+}
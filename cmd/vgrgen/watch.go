@@ -0,0 +1,289 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/vugu/vgrouter/rgen"
+)
+
+// watcher regenerates the routes for a directory when files which affect them
+// (see rgen.Generator.Affects) are added, removed, renamed or modified, since included
+// files can contain route metadata.
+type watcher struct {
+	g         *rgen.Generator
+	dir       string // absolute
+	recursive bool
+	quiet     bool
+
+	changes chan string     // directories in which something changed
+	dirs    map[string]bool // directories being watched with fsnotify
+}
+
+func newWatcher(g *rgen.Generator, dir string, recursive, quiet bool) *watcher {
+	return &watcher{
+		g:         g,
+		dir:       dir,
+		recursive: recursive,
+		quiet:     quiet,
+		changes:   make(chan string, 64),
+		dirs:      make(map[string]bool),
+	}
+}
+
+// run watches for changes forever.  File system notifications are used unless poll is true or
+// they are not available, in which case the directory is scanned every interval.  Changes are
+// collected until none have been seen for the debounce duration and then regenerated together.
+func (w *watcher) run(poll bool, interval, debounce time.Duration) {
+
+	if !poll {
+		err := w.startNotify()
+		if err != nil {
+			log.Printf("Unable to watch %s for changes, falling back to polling: %v", w.dir, err)
+			poll = true
+		}
+	}
+	if poll {
+		go w.poll(w.snapshot(), time.Tick(interval))
+	}
+
+	if !w.quiet {
+		log.Printf("Watching for changes in: %s", w.dir)
+	}
+
+	debounceChanges(w.changes, debounce, w.regenerate)
+}
+
+// debounceChanges collects the directories received from changes until none have been received
+// for the duration d and then calls f with them, until changes is closed.
+func debounceChanges(changes <-chan string, d time.Duration, f func(changed map[string]bool)) {
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(d)
+	timer.Stop()
+
+	for {
+		select {
+		case dir, ok := <-changes:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			pending[dir] = true
+			timer.Reset(d)
+		case <-timer.C:
+			f(pending)
+			pending = make(map[string]bool)
+		}
+	}
+}
+
+// regenerate renders the routes and writes the files for the changed directories and their
+// parents, which import them.
+func (w *watcher) regenerate(changed map[string]bool) {
+
+	affected := make(map[string]bool, len(changed))
+	for d := range changed {
+		for {
+			affected[d] = true
+			if d == w.dir || !strings.HasPrefix(d, w.dir) {
+				break
+			}
+			d = filepath.Dir(d)
+		}
+	}
+
+	files, err := w.g.Render()
+	if err != nil {
+		log.Printf("Error generating routes for %s: %v", w.dir, err)
+		return
+	}
+
+	var out []rgen.File
+	for _, f := range files {
+		if affected[filepath.Dir(f.Path)] {
+			out = append(out, f)
+		}
+	}
+
+	written, err := w.g.WriteFiles(out)
+	if err != nil {
+		log.Printf("Error writing routes for %s: %v", w.dir, err)
+	}
+	if w.quiet {
+		return
+	}
+	for _, p := range written {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			log.Printf("Removed: %s", w.rel(p))
+			continue
+		}
+		log.Printf("Wrote: %s", w.rel(p))
+	}
+}
+
+// rel returns p relative to the watched directory for logging.
+func (w *watcher) rel(p string) string {
+	r, err := filepath.Rel(w.dir, p)
+	if err != nil {
+		return p
+	}
+	return r
+}
+
+// affects returns true if a change to the file at p can change the generated routes.
+func (w *watcher) affects(p string) bool {
+	dir, name := filepath.Split(p)
+	rel, err := filepath.Rel(w.dir, dir)
+	if err != nil {
+		return false
+	}
+	rel = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(rel)), "/")
+	return w.g.Affects(rel, name)
+}
+
+// dirWatcher is the part of fsnotify.Watcher used to add and remove watched directories.
+type dirWatcher interface {
+	Add(name string) error
+	Remove(name string) error
+}
+
+// startNotify watches the directory tree using file system notifications.
+func (w *watcher) startNotify() error {
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	err = w.addDirs(fw, w.dir)
+	if err != nil {
+		fw.Close()
+		return err
+	}
+
+	go w.watchEvents(fw, fw.Events, fw.Errors)
+
+	return nil
+}
+
+// watchEvents handles the events from dw until events or errors is closed.
+func (w *watcher) watchEvents(dw dirWatcher, events <-chan fsnotify.Event, errors <-chan error) {
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			w.handleEvent(dw, ev)
+		case err, ok := <-errors:
+			if !ok {
+				return
+			}
+			log.Printf("Error watching %s: %v", w.dir, err)
+		}
+	}
+}
+
+// addDirs adds dir, and if recursive all directories under it, to dw.
+func (w *watcher) addDirs(dw dirWatcher, dir string) error {
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		if p != dir && !w.recursive {
+			return filepath.SkipDir
+		}
+		err = dw.Add(p)
+		if err != nil {
+			return err
+		}
+		w.dirs[p] = true
+		return nil
+	})
+}
+
+// handleEvent reports the directory in which ev happened if it can change the generated routes,
+// and keeps the directories watched by dw up to date.
+func (w *watcher) handleEvent(dw dirWatcher, ev fsnotify.Event) {
+
+	p := filepath.Clean(ev.Name)
+	parent := filepath.Dir(p)
+
+	switch {
+
+	case ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && w.dirs[p]:
+		delete(w.dirs, p)
+		dw.Remove(p)
+		w.changes <- parent
+
+	case ev.Op&fsnotify.Create != 0 && w.recursive && isDir(p):
+		err := w.addDirs(dw, p)
+		if err != nil {
+			log.Printf("Error watching %s: %v", p, err)
+		}
+		// files may have been created before the watch was added
+		w.changes <- p
+
+	case ev.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename|fsnotify.Write) != 0 && w.affects(p):
+		w.changes <- parent
+
+	}
+}
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
+
+// poll scans the directory tree on each tick and reports the directories in which an affecting
+// file was added, removed or modified since the previous scan (starting with prev).
+// It returns when ticks is closed.
+func (w *watcher) poll(prev map[string]time.Time, ticks <-chan time.Time) {
+
+	for range ticks {
+		cur := w.snapshot()
+		for p, t := range cur {
+			if pt, ok := prev[p]; !ok || !pt.Equal(t) {
+				w.changes <- filepath.Dir(p)
+			}
+		}
+		for p := range prev {
+			if _, ok := cur[p]; !ok {
+				w.changes <- filepath.Dir(p)
+			}
+		}
+		prev = cur
+	}
+}
+
+// snapshot returns the affecting files in the directory tree with their modification times.
+func (w *watcher) snapshot() map[string]time.Time {
+
+	ret := make(map[string]time.Time)
+	filepath.Walk(w.dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil // files may come and go while we walk
+		}
+		if fi.IsDir() {
+			if p != w.dir && !w.recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if w.affects(p) {
+			ret[p] = fi.ModTime()
+		}
+		return nil
+	})
+
+	return ret
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/vugu/vgrouter/rgen"
+)
+
+func TestDebounceChanges(t *testing.T) {
+
+	changes := make(chan string)
+	calls := make(chan map[string]bool, 10)
+	done := make(chan bool)
+	go func() {
+		debounceChanges(changes, 50*time.Millisecond, func(changed map[string]bool) { calls <- changed })
+		done <- true
+	}()
+
+	// changes close together are regenerated once
+	changes <- "/a"
+	changes <- "/b"
+	time.Sleep(10 * time.Millisecond)
+	changes <- "/a"
+	select {
+	case c := <-calls:
+		if !reflect.DeepEqual(c, map[string]bool{"/a": true, "/b": true}) {
+			t.Errorf("unexpected changes %v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for regeneration")
+	}
+
+	changes <- "/c"
+	select {
+	case c := <-calls:
+		if !reflect.DeepEqual(c, map[string]bool{"/c": true}) {
+			t.Errorf("unexpected changes %v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for regeneration")
+	}
+
+	close(changes)
+	<-done
+	if len(calls) != 0 {
+		t.Errorf("unexpected regeneration %v", <-calls)
+	}
+
+}
+
+func TestPoll(t *testing.T) {
+
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+	writeFile(t, filepath.Join(tmpDir, "index.vugu"))
+	writeFile(t, filepath.Join(tmpDir, "comps.go"))
+	writeFile(t, filepath.Join(tmpDir, "sub", "a.vugu"))
+
+	g := rgen.New().SetDir(tmpDir).SetRecursive(true).SetParseGo(true)
+	w := newWatcher(g, tmpDir, true, true)
+	prev := w.snapshot()
+
+	writeFile(t, filepath.Join(tmpDir, "sub", "b.vugu"))
+	writeFile(t, filepath.Join(tmpDir, "readme.txt"))
+	must(t, os.Remove(filepath.Join(tmpDir, "index.vugu")))
+	future := time.Now().Add(time.Hour)
+	must(t, os.Chtimes(filepath.Join(tmpDir, "comps.go"), future, future))
+	must(t, os.Chtimes(filepath.Join(tmpDir, "sub", "a.vugu"), future, future))
+	must(t, os.Chtimes(filepath.Join(tmpDir, "readme.txt"), future, future))
+
+	ticks := make(chan time.Time, 2)
+	ticks <- time.Now()
+	ticks <- time.Now() // nothing changed since the first
+	close(ticks)
+	w.poll(prev, ticks)
+
+	expected := []string{tmpDir, tmpDir, filepath.Join(tmpDir, "sub"), filepath.Join(tmpDir, "sub")}
+	if got := drain(w.changes); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected changes %v, got %v", expected, got)
+	}
+
+}
+
+// fakeDirWatcher records the directories added and removed.
+type fakeDirWatcher struct {
+	added, removed []string
+}
+
+func (f *fakeDirWatcher) Add(name string) error    { f.added = append(f.added, name); return nil }
+func (f *fakeDirWatcher) Remove(name string) error { f.removed = append(f.removed, name); return nil }
+
+func TestHandleEvent(t *testing.T) {
+
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+	sub := filepath.Join(tmpDir, "sub")
+	must(t, os.Mkdir(sub, 0755))
+
+	g := rgen.New().SetDir(tmpDir).SetRecursive(true).SetParseGo(true)
+	w := newWatcher(g, tmpDir, true, true)
+	dw := &fakeDirWatcher{}
+	must(t, w.addDirs(dw, tmpDir))
+	if !reflect.DeepEqual(dw.added, []string{tmpDir, sub}) {
+		t.Errorf("unexpected watched directories %v", dw.added)
+	}
+	dw.added = nil
+
+	newDir := filepath.Join(tmpDir, "new")
+	must(t, os.Mkdir(newDir, 0755))
+
+	type tcase struct {
+		name    string
+		op      fsnotify.Op
+		changed string // expected directory reported, empty for none
+	}
+	tcases := []tcase{
+		{"index.vugu", fsnotify.Create, tmpDir},
+		{"index.vugu", fsnotify.Write, tmpDir}, // may change route metadata
+		{"index.vugu", fsnotify.Chmod, ""},
+		{"sub/page.vugu", fsnotify.Rename, sub},
+		{"readme.txt", fsnotify.Create, ""},
+		{"comps.go", fsnotify.Write, tmpDir},
+		{"comps_test.go", fsnotify.Write, ""},
+		{rgen.DefaultOutputFile, fsnotify.Write, ""}, // our own output
+		{"new", fsnotify.Create, newDir},
+		{"sub", fsnotify.Remove, tmpDir},
+	}
+
+	events := make(chan fsnotify.Event, len(tcases))
+	for _, tc := range tcases {
+		events <- fsnotify.Event{Name: filepath.Join(tmpDir, tc.name), Op: tc.op}
+	}
+	close(events)
+	w.watchEvents(dw, events, make(chan error))
+
+	var expected []string
+	for _, tc := range tcases {
+		if tc.changed != "" {
+			expected = append(expected, tc.changed)
+		}
+	}
+	sort.Strings(expected)
+	if got := drain(w.changes); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected changes %v, got %v", expected, got)
+	}
+	if !reflect.DeepEqual(dw.added, []string{newDir}) {
+		t.Errorf("expected %s to be watched, got %v", newDir, dw.added)
+	}
+	if !reflect.DeepEqual(dw.removed, []string{sub}) {
+		t.Errorf("expected %s to be removed, got %v", sub, dw.removed)
+	}
+	if w.dirs[sub] || !w.dirs[newDir] {
+		t.Errorf("unexpected watched directories %v", w.dirs)
+	}
+
+}
+
+func TestRegenerate(t *testing.T) {
+
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+	writeFile(t, filepath.Join(tmpDir, "index.vugu"))
+	writeFile(t, filepath.Join(tmpDir, "a", "one.vugu"))
+	writeFile(t, filepath.Join(tmpDir, "b", "one.vugu"))
+
+	g := rgen.New().SetDir(tmpDir).SetRecursive(true).SetPackageName("example.com/x")
+	must(t, g.Generate())
+
+	writeFile(t, filepath.Join(tmpDir, "a", "two.vugu"))
+	writeFile(t, filepath.Join(tmpDir, "b", "two.vugu"))
+
+	// only the routes for the changed directory (and its parents) are written
+	w := newWatcher(g, tmpDir, true, true)
+	w.regenerate(map[string]bool{filepath.Join(tmpDir, "a"): true})
+
+	if b := readFile(t, filepath.Join(tmpDir, "a", rgen.DefaultOutputFile)); !strings.Contains(b, `"/two"`) {
+		t.Errorf("expected route for a/two.vugu, got:\n%s", b)
+	}
+	if b := readFile(t, filepath.Join(tmpDir, "b", rgen.DefaultOutputFile)); strings.Contains(b, `"/two"`) {
+		t.Errorf("unexpected route for b/two.vugu, got:\n%s", b)
+	}
+
+	diffs, err := g.Check()
+	must(t, err)
+	var stale []string
+	for _, d := range diffs {
+		stale = append(stale, d.Path)
+	}
+	if !reflect.DeepEqual(stale, []string{filepath.Join(tmpDir, "b", rgen.DefaultOutputFile)}) {
+		t.Errorf("expected only b to be stale, got %v", stale)
+	}
+
+}
+
+// drain returns the directories in changes without waiting, sorted.
+func drain(changes chan string) []string {
+	var ret []string
+	for len(changes) > 0 {
+		ret = append(ret, <-changes)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "vgrgen")
+	must(t, err)
+	// resolve symlinks (e.g. on macOS) so paths compare equal to those from filepath.Walk
+	dir, err = filepath.EvalSymlinks(dir)
+	must(t, err)
+	return dir
+}
+
+func writeFile(t *testing.T, p string) {
+	must(t, os.MkdirAll(filepath.Dir(p), 0755))
+	must(t, ioutil.WriteFile(p, []byte("<div></div>"), 0644))
+}
+
+func readFile(t *testing.T, p string) string {
+	b, err := ioutil.ReadFile(p)
+	must(t, err)
+	return string(b)
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+package vgrouter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vugu/vugu"
+)
+
+// RouteMatchSetter is implemented by components which want to receive the RouteMatch
+// that caused them to be shown, e.g. to read path params.
+type RouteMatchSetter interface {
+	RouteMatchSet(rm *RouteMatch)
+}
+
+// AddComponentRoutes adds an exact route for each path in m, the value of which must be a
+// component (vugu.Builder) or a func() vugu.Builder which returns one.  When the route is navigated
+// to, the component (from calling the function, if that's what was provided) receives the RouteMatch
+// if it implements RouteMatchSetter and then set is called with it.  Components which implement
+// NavigatorSetter are given this Router when the routes are added, or for functions each time one
+// is returned.  This is intended to be used with the route map generated by rgen.
+//
+// If more than one route matches exactly (e.g. "/user/:id" and "/user/new" both match "/user/new")
+// only the most specific one is used (see RouteMatch.IsSelected), the others do nothing, so set is
+// called once and only the component which is shown is constructed and given the RouteMatch.
+func (r *Router) AddComponentRoutes(m map[string]interface{}, set func(vugu.Builder)) error {
+
+	plist := make([]string, 0, len(m))
+	for p := range m {
+		plist = append(plist, p)
+	}
+	err := sortRoutePaths(plist)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range plist {
+
+		var f func() vugu.Builder
+		switch v := m[p].(type) {
+		case func() vugu.Builder:
+			f = func() vugu.Builder {
+				c := v()
+				if ns, ok := c.(NavigatorSetter); ok {
+					ns.NavigatorSet(r)
+				}
+				return c
+			}
+		case vugu.Builder:
+			if ns, ok := v.(NavigatorSetter); ok {
+				ns.NavigatorSet(r)
+			}
+			f = func() vugu.Builder { return v }
+		default:
+			return fmt.Errorf("route %q: %T does not implement vugu.Builder and is not a func() vugu.Builder", p, m[p])
+		}
+
+		err := r.AddRouteExact(p, RouteHandlerFunc(func(rm *RouteMatch) {
+			if !rm.IsSelected() {
+				return
+			}
+			c := f()
+			if rms, ok := c.(RouteMatchSetter); ok {
+				rms.RouteMatchSet(rm)
+			}
+			set(c)
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortRoutePaths sorts route paths from least to most specific, see mpath.compareSpecificity.
+func sortRoutePaths(plist []string) error {
+
+	mps := make(map[string]mpath, len(plist))
+	for _, p := range plist {
+		mp, err := parseMpath(p)
+		if err != nil {
+			return err
+		}
+		mps[p] = mp
+	}
+
+	sort.Slice(plist, func(i, j int) bool {
+		if d := mps[plist[i]].compareSpecificity(mps[plist[j]]); d != 0 {
+			return d < 0
+		}
+		return plist[i] < plist[j]
+	})
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package vgrouter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vugu/vugu"
+)
+
+type testComp struct {
+	NavigatorRef
+	name string
+	rm   *RouteMatch
+}
+
+func (c *testComp) Build(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) { return &vugu.BuildOut{} }
+
+func (c *testComp) RouteMatchSet(rm *RouteMatch) { c.rm = rm }
+
+func TestAddComponentRoutes(t *testing.T) {
+
+	index, userNew, user, rest := &testComp{name: "index"}, &testComp{name: "new"}, &testComp{name: "user"}, &testComp{name: "rest"}
+
+	r := New(nil)
+	var cur vugu.Builder
+	err := r.AddComponentRoutes(map[string]interface{}{
+		"/":           index,
+		"/user/new":   userNew,
+		"/user/:id":   user,
+		"/user/*rest": rest,
+	}, func(c vugu.Builder) { cur = c })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Navigator != r {
+		t.Errorf("Navigator not set")
+	}
+
+	r.process("/", nil)
+	if cur != index {
+		t.Errorf("expected index, got %#v", cur)
+	}
+
+	r.process("/user/123", nil)
+	if cur != user || user.rm.Params.Get("id") != "123" {
+		t.Errorf("expected user with id param, got %#v", cur)
+	}
+
+	r.process("/user/new", nil)
+	if cur != userNew {
+		t.Errorf("expected new, got %#v", cur)
+	}
+
+	r.process("/user/a/b", nil)
+	if cur != rest || rest.rm.Params.Get("rest") != "a/b" {
+		t.Errorf("expected rest, got %#v", cur)
+	}
+
+	// factories are called for each navigation
+	calls := 0
+	r = New(nil)
+	err = r.AddComponentRoutes(map[string]interface{}{
+		"/page": func() vugu.Builder { calls++; return &testComp{name: "page"} },
+	}, func(c vugu.Builder) { cur = c })
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.process("/page", nil)
+	first := cur.(*testComp)
+	r.process("/page", nil)
+	if calls != 2 || cur == first || first.Navigator != r || first.rm.Path != "/page" {
+		t.Errorf("expected a new component with Navigator and RouteMatch set for each navigation, got %d calls", calls)
+	}
+
+	// only the most specific exact match is constructed and set
+	built := map[string]int{}
+	sets := 0
+	r = New(nil)
+	err = r.AddComponentRoutes(map[string]interface{}{
+		"/x":       func() vugu.Builder { built["/x"]++; return &testComp{name: "x"} },
+		"/x/*rest": func() vugu.Builder { built["/x/*rest"]++; return &testComp{name: "rest"} },
+	}, func(c vugu.Builder) { sets++; cur = c })
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.process("/x", nil)
+	if sets != 1 || cur.(*testComp).name != "x" || built["/x"] != 1 || built["/x/*rest"] != 0 {
+		t.Errorf("expected only x to be built and set for /x, got %d sets, %#v, built %v", sets, cur, built)
+	}
+	r.process("/x/a/b", nil)
+	if sets != 2 || cur.(*testComp).name != "rest" || cur.(*testComp).rm.Params.Get("rest") != "a/b" || built["/x/*rest"] != 1 {
+		t.Errorf("expected rest for /x/a/b, got %d sets, %#v, built %v", sets, cur, built)
+	}
+
+	err = New(nil).AddComponentRoutes(map[string]interface{}{"/": "not a component"}, func(c vugu.Builder) {})
+	if err == nil {
+		t.Errorf("expected error for non-component")
+	}
+
+}
+
+func TestSortRoutePaths(t *testing.T) {
+
+	plist := []string{"/user/new", "/user/1st", "/", "/user/:id", "/user/*rest", "/user", "/user/:id/edit"}
+	err := sortRoutePaths(plist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/", "/user/*rest", "/user", "/user/:id", "/user/:id/edit", "/user/1st", "/user/new"}
+	if !reflect.DeepEqual(plist, expected) {
+		t.Errorf("expected %#v, got %#v", expected, plist)
+	}
+
+}
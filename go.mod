@@ -3,6 +3,7 @@ module github.com/vugu/vgrouter
 go 1.14
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/tdewolff/minify/v2 v2.7.3 // indirect
 	github.com/vugu/vugu v0.3.0
 )
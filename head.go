@@ -0,0 +1,156 @@
+package vgrouter
+
+import (
+	"html"
+	"sort"
+	"strings"
+)
+
+// Head is the document title and meta tags for a route.
+type Head struct {
+	Title       string            // document title
+	Description string            // content of <meta name="description">
+	Canonical   string            // href of <link rel="canonical">
+	Meta        map[string]string // content of other <meta> tags by name, e.g. "robots"
+}
+
+// HeadFunc returns the Head for a route match, e.g. with a title computed from rm.Params or rm.Data.
+type HeadFunc func(rm *RouteMatch) Head
+
+// HTML returns the tags for h, for use in server-side rendering.  Empty fields are omitted.
+func (h Head) HTML() string {
+
+	var sb strings.Builder
+	if h.Title != "" {
+		sb.WriteString("<title>" + html.EscapeString(h.Title) + "</title>\n")
+	}
+	if h.Description != "" {
+		sb.WriteString(`<meta name="description" content="` + html.EscapeString(h.Description) + "\">\n")
+	}
+	if h.Canonical != "" {
+		sb.WriteString(`<link rel="canonical" href="` + html.EscapeString(h.Canonical) + "\">\n")
+	}
+	for _, k := range h.metaNames() {
+		sb.WriteString(`<meta name="` + html.EscapeString(k) + `" content="` + html.EscapeString(h.Meta[k]) + "\">\n")
+	}
+
+	return sb.String()
+}
+
+// metaNames returns the sorted keys of h.Meta.
+func (h Head) metaNames() []string {
+	ret := make([]string, 0, len(h.Meta))
+	for k := range h.Meta {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// isZero returns true if h has no fields set.
+func (h Head) isZero() bool {
+	return h.Title == "" && h.Description == "" && h.Canonical == "" && len(h.Meta) == 0
+}
+
+// merge returns h with the non-empty fields from o replacing those in h.
+func (h Head) merge(o Head) Head {
+	if o.Title != "" {
+		h.Title = o.Title
+	}
+	if o.Description != "" {
+		h.Description = o.Description
+	}
+	if o.Canonical != "" {
+		h.Canonical = o.Canonical
+	}
+	if len(o.Meta) > 0 {
+		m := make(map[string]string, len(h.Meta)+len(o.Meta))
+		for k, v := range h.Meta {
+			m[k] = v
+		}
+		for k, v := range o.Meta {
+			m[k] = v
+		}
+		h.Meta = m
+	}
+	return h
+}
+
+// MustSetRouteHead is like SetRouteHead but panics upon error.
+func (r *Router) MustSetRouteHead(path string, h Head) {
+	err := r.SetRouteHead(path, h)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SetRouteHead sets a fixed Head for a route path (with params as :param).
+// See SetRouteHeadFunc.
+func (r *Router) SetRouteHead(path string, h Head) error {
+	return r.SetRouteHeadFunc(path, func(rm *RouteMatch) Head { return h })
+}
+
+// MustSetRouteHeadFunc is like SetRouteHeadFunc but panics upon error.
+func (r *Router) MustSetRouteHeadFunc(path string, f HeadFunc) {
+	err := r.SetRouteHeadFunc(path, f)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SetRouteHeadFunc sets the function which provides the Head for a route path (with params as :param).
+// Each time a path is processed, the Heads of the routes which match it are combined, starting with
+// the one from SetDefaultHead, then those of prefix matches and then of the most specific exact
+// match (see RouteMatch.IsSelected), with non-empty fields replacing earlier ones.  For routes
+// without a HeadFunc the "title" and "description" from their RouteMeta are used.  In the browser
+// the result is applied to the document, server-side it can be obtained with CurrentHead after
+// ProcessRequest.  Only the meta and link tags created by the router are changed, those already in
+// the page are left alone (so should be omitted if routes provide them), and the original title is
+// restored for paths without one.  Until a path has a non-empty Head the document is not changed.
+// The function is called after the route's handler, with the EventEnv lock held, and again when
+// resolver data arrives (see AddResolver).
+func (r *Router) SetRouteHeadFunc(path string, f HeadFunc) error {
+
+	mp, err := parseMpath(path)
+	if err != nil {
+		return err
+	}
+
+	if r.headMap == nil {
+		r.headMap = make(map[string]HeadFunc)
+	}
+	r.headMap[mp.String()] = f
+
+	return nil
+}
+
+// SetDefaultHead sets the Head used for fields which no matching route provides, e.g. the site name as Title.
+func (r *Router) SetDefaultHead(h Head) {
+	r.defaultHead = h
+}
+
+// CurrentHead returns the Head for the current path, see SetRouteHeadFunc.
+func (r *Router) CurrentHead() Head {
+	return r.curHead
+}
+
+// head returns the combined Head for matches.
+func (r *Router) head(matches []RouteMatch) Head {
+
+	ret := r.defaultHead
+	for _, selected := range []bool{false, true} {
+		for i := range matches {
+			rm := &matches[i]
+			if rm.selected != selected || (rm.Exact && !rm.selected) {
+				continue
+			}
+			if f := r.headMap[rm.RoutePath]; f != nil {
+				ret = ret.merge(f(rm))
+			} else if rm.Meta != nil {
+				ret = ret.merge(Head{Title: rm.Meta["title"], Description: rm.Meta["description"]})
+			}
+		}
+	}
+
+	return ret
+}
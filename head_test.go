@@ -0,0 +1,71 @@
+package vgrouter
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHead(t *testing.T) {
+
+	r := New(nil)
+	r.SetDefaultHead(Head{Title: "Site", Meta: map[string]string{"robots": "index"}})
+	r.MustAddRoute("/", RouteHandlerFunc(func(rm *RouteMatch) {}))
+	r.MustAddRoute("/users/:id", RouteHandlerFunc(func(rm *RouteMatch) {}))
+	r.MustAddRoute("/about", RouteHandlerFunc(func(rm *RouteMatch) {}))
+	r.MustAddRoute("/private", RouteHandlerFunc(func(rm *RouteMatch) {}))
+
+	r.MustSetRouteHead("/", Head{Description: "All about the site"})
+	r.MustSetRouteHeadFunc("/users/:id", func(rm *RouteMatch) Head {
+		return Head{Title: "User " + rm.Params.Get("id"), Canonical: "https://example.com/users/" + rm.Params.Get("id")}
+	})
+	r.MustSetRouteMeta("/about", RouteMeta{"title": "About"})
+	r.MustSetRouteHead("/private", Head{Meta: map[string]string{"robots": "noindex"}})
+
+	r.ProcessRequest(httptest.NewRequest("GET", "/users/5", nil))
+	expected := Head{
+		Title:       "User 5",
+		Description: "All about the site",
+		Canonical:   "https://example.com/users/5",
+		Meta:        map[string]string{"robots": "index"},
+	}
+	if h := r.CurrentHead(); !reflect.DeepEqual(h, expected) {
+		t.Errorf("expected head %#v, got %#v", expected, h)
+	}
+
+	r.process("/about", nil)
+	if h := r.CurrentHead(); h.Title != "About" || h.Description != "All about the site" || h.Canonical != "" {
+		t.Errorf("unexpected head from meta: %#v", h)
+	}
+
+	r.process("/private", nil)
+	if h := r.CurrentHead(); h.Title != "Site" || h.Meta["robots"] != "noindex" {
+		t.Errorf("unexpected head: %#v", h)
+	}
+
+	html := Head{
+		Title:       `Tom & "Jerry"`,
+		Description: "<b>",
+		Canonical:   "/a?b=1&c=2",
+		Meta:        map[string]string{"robots": "noindex", "author": "me"},
+	}.HTML()
+	expectedHTML := `<title>Tom &amp; &#34;Jerry&#34;</title>
+<meta name="description" content="&lt;b&gt;">
+<link rel="canonical" href="/a?b=1&amp;c=2">
+<meta name="author" content="me">
+<meta name="robots" content="noindex">
+`
+	if html != expectedHTML {
+		t.Errorf("expected HTML:\n%s\ngot:\n%s", expectedHTML, html)
+	}
+
+	if html := (Head{}).HTML(); html != "" {
+		t.Errorf("expected empty HTML, got %q", html)
+	}
+
+	// the document is left alone until there is something to apply
+	if !(Head{}).isZero() || (Head{Meta: map[string]string{"robots": "noindex"}}).isZero() {
+		t.Errorf("unexpected isZero result")
+	}
+
+}
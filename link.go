@@ -0,0 +1,177 @@
+package vgrouter
+
+import (
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/vugu/vugu"
+)
+
+// Link is a Vugu component which renders an <a> tag for a route.  The href is built
+// with the Router's path prefix and fragment settings (see Href), clicking it calls
+// Navigate instead of loading a new page, and classes are added based on whether the
+// Router's current path matches the link.
+//
+// Example usage in a .vugu file:
+//
+//	<vgrouter:Link :Router="c.Router" Path="/users/:id" :Params='url.Values{"id":{"1"}}'>User 1</vgrouter:Link>
+type Link struct {
+	Router *Router // the Router to navigate with, required
+
+	Path   string     // path to link to, may contain :param placeholders which are filled from Params
+	Name   string     // route name registered with NameRoute, used instead of Path if set
+	Params url.Values // values for :param placeholders; any not used in the path go in the query string
+
+	Class            string // class(es) always applied to the <a> tag
+	ActiveClass      string // class added when the current path is this path or below it, defaults to "active"
+	ExactActiveClass string // class added when the current path is exactly this path, defaults to "exact-active"
+
+	Prefetch bool // call Router.Prefetch for the link's path when the pointer enters it or it gets focus
+
+	Text        string       // text for the link, used if DefaultSlot is nil
+	DefaultSlot vugu.Builder // contents of the link
+}
+
+// pathQuery returns the path and query this link points to.
+// If ok is false the link could not be resolved.
+func (c *Link) pathQuery() (p string, q url.Values, ok bool) {
+
+	var err error
+	if c.Name != "" {
+		if c.Router == nil {
+			return "", nil, false
+		}
+		p, q, err = c.Router.pathFor(c.Name, c.Params)
+	} else {
+		var mp mpath
+		mp, err = parseMpath(c.Path)
+		if err != nil {
+			return "", nil, false
+		}
+		p, q, err = mp.merge(c.Params)
+	}
+
+	// a missing param still gives us a usable path
+	if err != nil && err != errMissingParam {
+		return "", nil, false
+	}
+
+	return p, q, true
+}
+
+// classNames returns the full class attribute value for the link to path p.
+func (c *Link) classNames(p string) string {
+
+	classes := make([]string, 0, 3)
+	if c.Class != "" {
+		classes = append(classes, c.Class)
+	}
+
+	if c.Router != nil && c.Router.IsActive(p) {
+		ac := c.ActiveClass
+		if ac == "" {
+			ac = "active"
+		}
+		classes = append(classes, ac)
+
+		if c.Router.IsExactActive(p) {
+			eac := c.ExactActiveClass
+			if eac == "" {
+				eac = "exact-active"
+			}
+			classes = append(classes, eac)
+		}
+	}
+
+	return strings.Join(classes, " ")
+}
+
+// Build implements vugu.Builder.
+func (c *Link) Build(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {
+
+	vgout = &vugu.BuildOut{}
+
+	vgn := &vugu.VGNode{Type: vugu.ElementNode, Data: "a"}
+	vgout.Out = append(vgout.Out, vgn)
+
+	cl := c.Class
+	p, q, ok := c.pathQuery()
+	if ok {
+		r := c.Router
+		if r == nil {
+			r = &Router{}
+		}
+		vgn.Attr = append(vgn.Attr, vugu.VGAttribute{Key: "href", Val: r.Href(p, q)})
+		cl = c.classNames(p)
+	}
+
+	if cl != "" {
+		vgn.Attr = append(vgn.Attr, vugu.VGAttribute{Key: "class", Val: cl})
+	}
+
+	vgn.DOMEventHandlerSpecList = append(vgn.DOMEventHandlerSpecList, vugu.DOMEventHandlerSpec{
+		EventType: "click",
+		Func:      c.handleClick,
+	})
+
+	if c.Prefetch {
+		for _, et := range []string{"mouseenter", "focus"} {
+			vgn.DOMEventHandlerSpecList = append(vgn.DOMEventHandlerSpecList, vugu.DOMEventHandlerSpec{
+				EventType: et,
+				Func:      c.handlePrefetch,
+			})
+		}
+	}
+
+	if c.DefaultSlot != nil {
+		vgin.BuildEnv.WireComponent(c.DefaultSlot)
+		vgout.Components = append(vgout.Components, c.DefaultSlot)
+		vgn.AppendChild(&vugu.VGNode{Component: c.DefaultSlot})
+	} else if c.Text != "" {
+		vgn.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: c.Text})
+	}
+
+	return vgout
+}
+
+// handleClick navigates to the link's path unless a modifier key was held
+// or a button other than the primary one was used.
+func (c *Link) handleClick(event vugu.DOMEvent) {
+
+	if c.Router == nil ||
+		event.PropFloat64("button") != 0 ||
+		event.PropBool("metaKey") ||
+		event.PropBool("ctrlKey") ||
+		event.PropBool("shiftKey") ||
+		event.PropBool("altKey") {
+		return
+	}
+
+	p, q, ok := c.pathQuery()
+	if !ok {
+		return
+	}
+
+	event.PreventDefault()
+
+	err := c.Router.Navigate(p, q)
+	if err != nil {
+		log.Printf("Link: error from Navigate: %v", err)
+	}
+}
+
+// handlePrefetch prefetches the link's path.
+func (c *Link) handlePrefetch(event vugu.DOMEvent) {
+
+	if c.Router == nil {
+		return
+	}
+
+	p, q, ok := c.pathQuery()
+	if !ok {
+		return
+	}
+
+	c.Router.Prefetch(p, q)
+}
@@ -0,0 +1,52 @@
+package vgrouter
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/vugu/vugu"
+)
+
+func TestLink(t *testing.T) {
+
+	attrs := func(out *vugu.BuildOut) map[string]string {
+		ret := make(map[string]string)
+		for _, a := range out.Out[0].Attr {
+			ret[a.Key] = a.Val
+		}
+		return ret
+	}
+
+	r := New(nil)
+	r.MustNameRoute("user", "/users/:id")
+	r.MustAddRoute("/users/:id", RouteHandlerFunc(func(rm *RouteMatch) {}))
+	r.process("/users/1", nil)
+
+	a := attrs((&Link{Router: r, Path: "/users/1"}).Build(nil))
+	if a["href"] != "/users/1" || a["class"] != "active exact-active" {
+		t.Errorf("unexpected attrs: %#v", a)
+	}
+
+	a = attrs((&Link{Router: r, Path: "/users", Class: "nav"}).Build(nil))
+	if a["href"] != "/users" || a["class"] != "nav active" {
+		t.Errorf("unexpected attrs: %#v", a)
+	}
+
+	a = attrs((&Link{Router: r, Name: "user", Params: url.Values{"id": {"2"}, "p": {"x"}}, ActiveClass: "on"}).Build(nil))
+	if a["href"] != "/users/2?p=x" || a["class"] != "" {
+		t.Errorf("unexpected attrs: %#v", a)
+	}
+
+	a = attrs((&Link{Router: r, Name: "missing"}).Build(nil))
+	if _, ok := a["href"]; ok {
+		t.Errorf("unexpected href for missing route name: %#v", a)
+	}
+
+	r.SetPathPrefix("/pfx")
+	r.SetUseFragment(true)
+	a = attrs((&Link{Router: r, Path: "/users/:id", Params: url.Values{"id": {"1"}}, ExactActiveClass: "here"}).Build(nil))
+	if a["href"] != "#/pfx/users/1" || a["class"] != "active here" {
+		t.Errorf("unexpected attrs: %#v", a)
+	}
+
+}
@@ -0,0 +1,155 @@
+package vgrouter
+
+import (
+	"errors"
+	"sync"
+)
+
+// RouteLoader provides the handler for a route which is loaded when it is first navigated to,
+// e.g. by fetching and instantiating a separate wasm module, so the code for rarely used pages
+// does not need to be in the main program.
+type RouteLoader interface {
+	// RouteLoad is called with the route path (as passed to AddRouteLoader) in its own goroutine,
+	// without the EventEnv lock held, and may block for as long as loading takes.
+	RouteLoad(path string) (RouteHandler, error)
+}
+
+// RouteLoaderFunc implements RouteLoader as a function.
+type RouteLoaderFunc func(path string) (RouteHandler, error)
+
+// RouteLoad implements RouteLoader.
+func (f RouteLoaderFunc) RouteLoad(path string) (RouteHandler, error) { return f(path) }
+
+// errNilHandler is the LoadErr when a RouteLoader returns neither a handler nor an error.
+var errNilHandler = errors.New("route loader returned a nil handler")
+
+// routeLoad is the state of a route added with AddRouteLoader.
+type routeLoad struct {
+	router *Router
+	mp     mpath
+	loader RouteLoader
+
+	mu      sync.Mutex
+	loading bool
+	handler RouteHandler // cached once loaded
+	err     error        // from a failed load, reported by the next handle and then cleared
+}
+
+// MustAddRouteLoader is like AddRouteLoader but panics upon error.
+func (r *Router) MustAddRouteLoader(path string, l RouteLoader) {
+	err := r.AddRouteLoader(path, l)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// AddRouteLoader adds a route whose handler is obtained from l the first time the route matches.
+// Until the handler is loaded the route matches are passed to the handler set with SetLoadHandler
+// with RouteMatch.Loading set, and if loading fails RouteMatch.LoadErr is set instead.
+// When loading finishes the EventEnv lock is acquired and, if the route still matches the current
+// path, the path is processed again, so the loaded handler (or the load handler with the error) is
+// called along with those of the other matching routes, and a render is requested.  A loaded handler
+// is kept and used for all later matches; after an error loading is tried again the next time the
+// route is navigated to.  Without an EventEnv (see New) the current path is not processed again and
+// a loaded handler is only used the next time the route matches.
+func (r *Router) AddRouteLoader(path string, l RouteLoader) error {
+
+	mp, err := parseMpath(path)
+	if err != nil {
+		return err
+	}
+
+	rl := &routeLoad{router: r, mp: mp, loader: l}
+	r.rlist = append(r.rlist, routeEntry{
+		mpath: mp,
+		rh:    RouteHandlerFunc(rl.handle),
+		load:  rl,
+	})
+
+	return nil
+}
+
+// SetLoadHandler sets the handler called for routes added with AddRouteLoader while they are
+// loading (RouteMatch.Loading is true) or if loading failed (RouteMatch.LoadErr is set).
+func (r *Router) SetLoadHandler(rh RouteHandler) {
+	r.loadHandler = rh
+}
+
+// handle is the RouteHandler for the route, it starts loading if needed.
+func (rl *routeLoad) handle(rm *RouteMatch) {
+
+	h, err := rl.start()
+	if h != nil {
+		h.RouteHandle(rm)
+		return
+	}
+
+	if err != nil {
+		rm.LoadErr = err
+	} else {
+		rm.Loading = true
+	}
+	if rl.router.loadHandler != nil {
+		rl.router.loadHandler.RouteHandle(rm)
+	}
+}
+
+// start returns the handler if loaded, or else the error from a failed load if it has not been
+// reported yet, or else starts loading if it is not already in progress.
+func (rl *routeLoad) start() (RouteHandler, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.err != nil {
+		err := rl.err
+		rl.err = nil
+		return nil, err
+	}
+	if rl.handler == nil && !rl.loading {
+		rl.loading = true
+		go rl.load()
+	}
+	return rl.handler, nil
+}
+
+// load calls the loader and then processes the current path again if the route still matches it.
+func (rl *routeLoad) load() {
+
+	h, err := rl.loader.RouteLoad(rl.mp.String())
+	if h == nil && err == nil {
+		err = errNilHandler
+	}
+
+	r := rl.router
+	if r.eventEnv == nil {
+		// without an EventEnv there is no lock for the router state, so a loaded
+		// handler is only kept for the next time the route is processed
+		rl.mu.Lock()
+		rl.loading = false
+		if err == nil {
+			rl.handler = h
+		}
+		rl.mu.Unlock()
+		return
+	}
+
+	r.eventEnv.Lock()
+
+	current := r.matchRoute(rl.mp, r.curPath, r.curQuery, nil) != nil
+
+	rl.mu.Lock()
+	rl.loading = false
+	if err == nil {
+		rl.handler = h
+	} else if current {
+		rl.err = err
+	}
+	rl.mu.Unlock()
+
+	if !current {
+		r.eventEnv.UnlockOnly()
+		return
+	}
+
+	r.reprocess()
+	r.eventEnv.UnlockRender()
+}
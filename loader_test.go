@@ -0,0 +1,114 @@
+package vgrouter
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testEventEnv struct {
+	sync.Mutex
+	renders chan bool
+}
+
+func (e *testEventEnv) UnlockOnly()   { e.Unlock() }
+func (e *testEventEnv) UnlockRender() { e.Unlock(); e.renders <- true }
+
+func TestRouteLoader(t *testing.T) {
+
+	ee := &testEventEnv{renders: make(chan bool, 10)}
+	r := New(ee)
+
+	var loaded []string
+	var loadStates []string
+	r.SetLoadHandler(RouteHandlerFunc(func(rm *RouteMatch) {
+		switch {
+		case rm.Loading:
+			loadStates = append(loadStates, "loading "+rm.Path)
+		case rm.LoadErr != nil:
+			loadStates = append(loadStates, "error "+rm.Path)
+		}
+	}))
+
+	prefixCalls := 0
+	r.MustAddRoute("/admin", RouteHandlerFunc(func(rm *RouteMatch) { prefixCalls++ }))
+
+	results := make(chan error)
+	calls := 0
+	r.MustAddRouteLoader("/admin/:id", RouteLoaderFunc(func(path string) (RouteHandler, error) {
+		calls++
+		if err := <-results; err != nil {
+			return nil, err
+		}
+		return RouteHandlerFunc(func(rm *RouteMatch) {
+			loaded = append(loaded, rm.Params.Get("id"))
+		}), nil
+	}))
+
+	// first attempt fails
+	ee.Lock()
+	r.process("/admin/1", nil)
+	ee.Unlock()
+	results <- errors.New("fetch failed")
+	<-ee.renders
+
+	// second attempt succeeds, the current path is handled when it is done
+	ee.Lock()
+	r.process("/admin/2", nil)
+	ee.Unlock()
+	results <- nil
+	<-ee.renders
+
+	// then the loaded handler is used directly
+	ee.Lock()
+	r.process("/admin/3", nil)
+	// the current path is processed again after each load, so the other routes are handled too
+	if prefixCalls != 5 {
+		t.Errorf("expected 5 calls to the /admin handler, got %d", prefixCalls)
+	}
+	if rm, ok := r.CurrentExact(); !ok || rm.RoutePath != "/admin/:id" {
+		t.Errorf("unexpected exact match %#v", rm)
+	}
+	expected := []string{"loading /admin/1", "error /admin/1", "loading /admin/2"}
+	if !reflect.DeepEqual(loadStates, expected) {
+		t.Errorf("expected load states %v, got %v", expected, loadStates)
+	}
+	if !reflect.DeepEqual(loaded, []string{"2", "3"}) {
+		t.Errorf("expected loaded handler to be called for 2 and 3, got %v", loaded)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls to loader, got %d", calls)
+	}
+	ee.Unlock()
+
+}
+
+func TestRouteLoaderNoEventEnv(t *testing.T) {
+
+	r := New(nil)
+
+	handled := make(chan string, 10)
+	r.SetLoadHandler(RouteHandlerFunc(func(rm *RouteMatch) { handled <- "loading" }))
+	r.MustAddRouteLoader("/lazy", RouteLoaderFunc(func(path string) (RouteHandler, error) {
+		return RouteHandlerFunc(func(rm *RouteMatch) { handled <- "loaded" }), nil
+	}))
+
+	// without a lock the current path is not processed again, the handler is used next time
+	r.process("/lazy", nil)
+	for i := 0; ; i++ {
+		if h := <-handled; h == "loaded" {
+			break
+		}
+		if i > 1000 {
+			t.Fatal("loaded handler was not used")
+		}
+		time.Sleep(time.Millisecond)
+		r.process("/lazy", nil)
+	}
+	if len(handled) != 0 {
+		t.Errorf("unexpected extra handler call: %s", <-handled)
+	}
+
+}
@@ -0,0 +1,196 @@
+package vgrouter
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// MustSetLocales is like SetLocales but panics upon error.
+func (r *Router) MustSetLocales(locales ...string) {
+	err := r.SetLocales(locales...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SetLocales enables locale prefixes, e.g. "/en/products" and "/de/produkte".  When a URL is read
+// from the browser, a link is clicked or a request is processed, a first path segment which is one
+// of locales is removed from the path and becomes the current locale (see Locale), otherwise the
+// current locale is kept (initially the first locale given).  Routes are added without the locale and with untranslated
+// segments (see SetSegmentTranslations), and the locale is provided as RouteMatch.Locale.
+// Paths passed to Navigate are also without the locale, and outgoing URLs (Navigate, Href, URLFor)
+// are given the current locale's prefix and translations.  A first path segment which is a
+// locale is always treated as one, so routes should not start with one.
+// Like SetPathPrefix, it should be called immediately after creation.
+func (r *Router) SetLocales(locales ...string) error {
+
+	for _, l := range locales {
+		if l == "" || strings.ContainsAny(l, "/?#") {
+			return fmt.Errorf("invalid locale %q", l)
+		}
+	}
+
+	r.locales = locales
+	r.locale = ""
+	if len(locales) > 0 {
+		r.locale = locales[0]
+	}
+
+	return nil
+}
+
+// SetSegmentTranslations sets the translations of static path segments for locale, with the keys
+// being segments of the paths passed to AddRoute and the values what is used in URLs for the
+// locale, e.g. {"products": "produkte"}.  A segment is only translated where a route which matches
+// the path has it as a static segment, so param values are left alone.
+func (r *Router) SetSegmentTranslations(locale string, segments map[string]string) {
+	if r.segmentMap == nil {
+		r.segmentMap = make(map[string]map[string]string)
+	}
+	r.segmentMap[locale] = segments
+}
+
+// Locale returns the current locale, or an empty string if SetLocales was not called.
+func (r *Router) Locale() string {
+	return r.locale
+}
+
+// SetLocale sets the current locale, which is used for outgoing URLs.  Navigating to the current
+// path afterward will update the browser URL.  It should usually be one passed to SetLocales.
+func (r *Router) SetLocale(locale string) {
+	r.locale = locale
+}
+
+// LocaleHref is like Href but for locale instead of the current locale.
+func (r *Router) LocaleHref(locale, path string, query url.Values) string {
+	pq := r.localePathQuery(locale, path, query)
+	if r.useFragment {
+		return "#" + pq
+	}
+	return pq
+}
+
+// CurrentLocaleHref returns the href for the current path and query in locale,
+// e.g. for links which switch language but keep the user on the same page.
+func (r *Router) CurrentLocaleHref(locale string) string {
+	return r.LocaleHref(locale, r.curPath, r.curQuery)
+}
+
+// delocalize returns the locale from the first segment of p (or the first one passed to SetLocales
+// if it has none) and p without it and with the translated segments for the locale put back.
+// If no locales are set it returns the current locale and p unchanged.
+func (r *Router) delocalize(p string) (locale, path string) {
+
+	if len(r.locales) == 0 {
+		return r.locale, p
+	}
+
+	// without a locale prefix, e.g. a plain link, the current locale is kept
+	locale, path = r.locale, p
+	if locale == "" {
+		locale = r.locales[0]
+	}
+	seg := strings.TrimPrefix(p, "/")
+	rest := ""
+	if i := strings.Index(seg, "/"); i >= 0 {
+		seg, rest = seg[:i], seg[i:]
+	}
+	if containsLocale(r.locales, seg) {
+		locale, path = seg, rest
+		if path == "" {
+			path = "/"
+		}
+	}
+
+	if tr := r.segmentMap[locale]; len(tr) > 0 {
+		path = r.translatePath(path, tr, true)
+	}
+
+	return locale, path
+}
+
+// localizePath returns p with the locale prefix and the translated segments for locale.
+// If no locales are set p is returned unchanged.
+func (r *Router) localizePath(locale, p string) string {
+
+	if len(r.locales) == 0 || locale == "" {
+		return p
+	}
+
+	if tr := r.segmentMap[locale]; len(tr) > 0 {
+		p = r.translatePath(p, tr, false)
+	}
+
+	if p == "/" || p == "" {
+		return "/" + locale
+	}
+	return "/" + locale + p
+}
+
+// translatePath replaces the segments of p which are static segments of the routes matching it
+// with their translations from tr.  If toRoute is true it does the reverse: p is a translated path
+// and is matched against the routes with their segments translated, and the segments are replaced
+// with the routes' ones.
+func (r *Router) translatePath(p string, tr map[string]string, toRoute bool) string {
+
+	parts := strings.Split(path.Clean("/"+p), "/")[1:]
+	out := make([]string, len(parts))
+	copy(out, parts)
+
+	changed := false
+	done := make([]bool, len(parts))
+	for _, re := range r.rlist {
+
+		mp := re.mpath
+		if toRoute {
+			mp = translateMpath(re.mpath, tr)
+		}
+		if _, _, ok := mp.match(p); !ok {
+			continue
+		}
+
+		for i, part := range mp {
+			if i >= len(parts) || strings.HasPrefix(part, "/*") {
+				break
+			}
+			if done[i] || part == "/" || strings.HasPrefix(part, "/:") {
+				continue
+			}
+			done[i] = true
+			if toRoute {
+				out[i] = re.mpath[i][1:]
+			} else if t, ok := tr[parts[i]]; ok {
+				out[i] = t
+			}
+			changed = changed || out[i] != parts[i]
+		}
+	}
+
+	if !changed {
+		return p
+	}
+	return "/" + strings.Join(out, "/")
+}
+
+// translateMpath returns mp with its static segments translated by tr.
+func translateMpath(mp mpath, tr map[string]string) mpath {
+	ret := make(mpath, len(mp))
+	for i, part := range mp {
+		ret[i] = part
+		if t, ok := tr[part[1:]]; ok && part != "/" && !strings.HasPrefix(part, "/:") && !strings.HasPrefix(part, "/*") {
+			ret[i] = "/" + t
+		}
+	}
+	return ret
+}
+
+func containsLocale(locales []string, l string) bool {
+	for _, v := range locales {
+		if v == l {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package vgrouter
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestLocales(t *testing.T) {
+
+	r := New(nil)
+	r.SetPathPrefix("/app")
+	r.MustSetLocales("en", "de")
+	r.SetSegmentTranslations("de", map[string]string{"products": "produkte", "new": "neu"})
+
+	var out []RouteMatch
+	h := RouteHandlerFunc(func(rm *RouteMatch) { out = append(out, *rm) })
+	r.MustAddRoute("/", h)
+	r.MustAddRoute("/products", h)
+	r.MustAddRoute("/products/:slug", h)
+	r.MustAddRoute("/products/new", h)
+
+	exact := func() RouteMatch {
+		rm, _ := r.CurrentExact()
+		return rm
+	}
+
+	type tcase struct {
+		in        string // request path
+		path      string // expected path
+		routePath string // expected route path of the exact match
+		locale    string // expected locale
+	}
+	for _, tc := range []tcase{
+		{"/de/produkte/neu", "/products/new", "/products/new", "de"},
+		{"/de/produkte/neu-ware", "/products/neu-ware", "/products/:slug", "de"},
+		{"/de/produkte", "/products", "/products", "de"},
+		{"/de", "/", "/", "de"},
+		{"/en/products/produkte", "/products/produkte", "/products/:slug", "en"},
+		{"/products", "/products", "/products", "en"},
+	} {
+		out = nil
+		r.ProcessRequest(httptest.NewRequest("GET", tc.in, nil))
+		rm := exact()
+		if r.CurrentPath() != tc.path || rm.RoutePath != tc.routePath || rm.Locale != tc.locale || r.Locale() != tc.locale {
+			t.Errorf("%s: expected path %q route %q locale %q, got %q %q %q", tc.in, tc.path, tc.routePath, tc.locale,
+				r.CurrentPath(), rm.RoutePath, rm.Locale)
+		}
+		for _, m := range out {
+			if m.Locale != tc.locale {
+				t.Errorf("%s: expected locale %q for %s, got %q", tc.in, tc.locale, m.RoutePath, m.Locale)
+			}
+		}
+	}
+
+	// the path prefix is removed from requests before the locale
+	r.ProcessRequest(httptest.NewRequest("GET", "/app/de/produkte", nil))
+	if rm := exact(); r.CurrentPath() != "/products" || rm.RoutePath != "/products" || r.Locale() != "de" {
+		t.Errorf("unexpected path %q route %q locale %q for prefixed request", r.CurrentPath(), rm.RoutePath, r.Locale())
+	}
+
+	// paths without a locale keep the current one
+	r.ProcessRequest(httptest.NewRequest("GET", "/app/produkte", nil))
+	if rm := exact(); r.CurrentPath() != "/products" || rm.RoutePath != "/products" || r.Locale() != "de" {
+		t.Errorf("unexpected path %q route %q locale %q for request without locale", r.CurrentPath(), rm.RoutePath, r.Locale())
+	}
+
+	// the prefix is only removed at a segment boundary
+	r.ProcessRequest(httptest.NewRequest("GET", "/application", nil))
+	if r.CurrentPath() != "/application" {
+		t.Errorf("unexpected path %q for request not under the prefix", r.CurrentPath())
+	}
+
+	// outgoing URLs use the current locale
+	r.ProcessRequest(httptest.NewRequest("GET", "/de/produkte/abc", nil))
+	if h := r.Href("/products/new", url.Values{"p": {"1"}}); h != "/app/de/produkte/neu?p=1" {
+		t.Errorf("unexpected href %q", h)
+	}
+	if h := r.Href("/products/new-stuff", nil); h != "/app/de/produkte/new-stuff" {
+		t.Errorf("unexpected href %q", h)
+	}
+	if h := r.Href("/", nil); h != "/app/de" {
+		t.Errorf("unexpected href %q", h)
+	}
+
+	// switching language keeps the page
+	r.ProcessRequest(httptest.NewRequest("GET", "/de/produkte/neu?q=x", nil))
+	if h := r.CurrentLocaleHref("en"); h != "/app/en/products/new?q=x" {
+		t.Errorf("unexpected href %q", h)
+	}
+	r.ProcessRequest(httptest.NewRequest("GET", "/en/products/new", nil))
+	if h := r.CurrentLocaleHref("de"); h != "/app/de/produkte/neu" {
+		t.Errorf("unexpected href %q", h)
+	}
+
+	r.SetLocale("de")
+	r.SetUseFragment(true)
+	if h := r.LocaleHref("en", "/products", nil); h != "#/app/en/products" {
+		t.Errorf("unexpected href %q", h)
+	}
+
+	if err := r.SetLocales("en", "a/b"); err == nil {
+		t.Errorf("expected error for invalid locale")
+	}
+
+}
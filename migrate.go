@@ -0,0 +1,60 @@
+package vgrouter
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// migrateURL examines the full browser location and if it is in the "other" form (i.e. a fragment
+// route like /#/a while in path mode, or a path route like /a while in fragment mode) returns the
+// canonical URL to replace it with and true.  If no migration is needed it returns false.
+// In fragment mode the document is assumed to be served at the path prefix (or the site root if
+// there is none), e.g. /app/a with the prefix /app becomes /app/#/app/a.
+func migrateURL(loc *url.URL, useFragment bool, pathPrefix string) (string, bool) {
+
+	if useFragment {
+
+		// already has a fragment route or is just the document itself, nothing to do
+		if loc.Fragment != "" || isDocRoot(loc.Path, pathPrefix) {
+			return "", false
+		}
+
+		// the route keeps the prefix since in fragment mode it is part of the fragment
+		doc := "/"
+		if pathPrefix != "" && strings.HasPrefix(loc.Path, pathPrefix+"/") {
+			doc = (&url.URL{Path: pathPrefix + "/"}).EscapedPath()
+		}
+
+		ret := doc + "#" + loc.EscapedPath()
+		if loc.RawQuery != "" {
+			ret = ret + "?" + loc.RawQuery
+		}
+		return ret, true
+	}
+
+	// path mode, only fragments that look like a path are considered routes
+	f := escapedFragment(loc)
+	if !strings.HasPrefix(f, "/") {
+		return "", false
+	}
+
+	return f, true
+}
+
+// escapedFragment returns the fragment of u, which for a route is a path and query, escaped so it
+// can be parsed as a URL.  The path is escaped as by url.URL.EscapedPath and the query as a fragment.
+// The original escaping is not available before Go 1.15, so e.g. an escaped "&" in the query is not kept.
+func escapedFragment(u *url.URL) string {
+	p, q := u.Fragment, ""
+	if i := strings.Index(p, "?"); i >= 0 {
+		p, q = p[:i], p[i:]
+	}
+	return (&url.URL{Path: p}).EscapedPath() + strings.TrimPrefix((&url.URL{Fragment: q}).String(), "#")
+}
+
+// isDocRoot returns true if p is the path of the document itself rather than a route.
+func isDocRoot(p, pathPrefix string) bool {
+	return p == "" || p == "/" || path.Base(p) == "index.html" ||
+		(pathPrefix != "" && (p == pathPrefix || p == pathPrefix+"/"))
+}
@@ -0,0 +1,61 @@
+package vgrouter
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMigrateURL(t *testing.T) {
+
+	var tlist = []struct {
+		in          string
+		useFragment bool
+		out         string
+		ok          bool
+		prefix      string
+	}{
+		// path mode, hash routes get migrated
+		{"http://localhost/#/a", false, "/a", true, ""},
+		{"http://localhost/#/a/b?p=1", false, "/a/b?p=1", true, ""},
+		{"http://localhost/index.html#/a?p=x%20y", false, "/a?p=x%20y", true, ""},
+		{"http://localhost/#/a%20b/c", false, "/a%20b/c", true, ""},
+		{"http://localhost/#/pfx/a", false, "/pfx/a", true, ""},
+		// path mode, already canonical or in-page anchors
+		{"http://localhost/a", false, "", false, ""},
+		{"http://localhost/a?p=1", false, "", false, ""},
+		{"http://localhost/a#section1", false, "", false, ""},
+		{"http://localhost/", false, "", false, ""},
+
+		// fragment mode, path routes get migrated
+		{"http://localhost/a", true, "/#/a", true, ""},
+		{"http://localhost/a/b?p=1", true, "/#/a/b?p=1", true, ""},
+		{"http://localhost/pfx/a", true, "/#/pfx/a", true, ""},
+		// fragment mode, already canonical or document root
+		{"http://localhost/#/a", true, "", false, ""},
+		{"http://localhost/", true, "", false, ""},
+		{"http://localhost/index.html", true, "", false, ""},
+		{"http://localhost/app/index.html", true, "", false, ""},
+		// fragment mode with a path prefix keeps the document path
+		{"http://localhost/app/a", true, "/app/#/app/a", true, "/app"},
+		{"http://localhost/app/a/b?p=1", true, "/app/#/app/a/b?p=1", true, "/app"},
+		{"http://localhost/app/", true, "", false, "/app"},
+		{"http://localhost/app", true, "", false, "/app"},
+		{"http://localhost/app/index.html", true, "", false, "/app"},
+		{"http://localhost/app/#/app/a", false, "/app/a", true, "/app"},
+	}
+
+	for _, ti := range tlist {
+		ti := ti
+		t.Run(ti.in, func(t *testing.T) {
+			u, err := url.Parse(ti.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, ok := migrateURL(u, ti.useFragment, ti.prefix)
+			if ok != ti.ok || out != ti.out {
+				t.Errorf("useFragment=%v prefix=%q: expected (%q, %v), got (%q, %v)", ti.useFragment, ti.prefix, ti.out, ti.ok, out, ok)
+			}
+		})
+	}
+
+}
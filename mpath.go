@@ -3,6 +3,7 @@ package vgrouter
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"net/url"
 	"path"
 	"strings"
@@ -11,6 +12,7 @@ import (
 // parseMpath will split p into appropriate parts for an mpath.
 // After parsing each element of mpath will start with a slash,
 // and if it's a parameter it will be followed by a colon.
+// A catch-all parameter must be the last element.
 func parseMpath(p string) (mpath, error) {
 	ret := make(mpath, 0, 2)
 	p = path.Clean("/" + p)
@@ -31,6 +33,12 @@ func parseMpath(p string) (mpath, error) {
 		ret = append(ret, str)
 	}
 
+	for _, part := range ret[:len(ret)-1] {
+		if strings.HasPrefix(part, "/*") {
+			return nil, fmt.Errorf("catch-all parameter %q must be the last part of route path %q", part[1:], p)
+		}
+	}
+
 	// lastWasSlash := false
 	// inParam := false
 	// startIdx := 0
@@ -69,6 +77,8 @@ func parseMpath(p string) (mpath, error) {
 
 // mpath is a matchable-path.
 // It's split so each element starts with a slash.
+// Elements of the form "/:name" match a single path segment and
+// "/*name" (catch-all, must be last) matches the entire rest of the path.
 type mpath []string
 
 // TODO: we'll need to know the static prefix when we get into using trie stuff
@@ -81,11 +91,12 @@ type mpath []string
 
 // paramNames will return the parameter names
 // without the preceding colon, i.e. the path "/somewhere/:p1/:p2"
-// will return []string{"p1","p2"}
+// will return []string{"p1","p2"}.  Catch-all parameter names are
+// included without the preceding asterisk.
 func (mp mpath) paramNames() []string {
 	var ret []string
 	for _, p := range mp {
-		if strings.HasPrefix(p, "/:") {
+		if strings.HasPrefix(p, "/:") || strings.HasPrefix(p, "/*") {
 			ret = append(ret, p[2:])
 		}
 	}
@@ -118,7 +129,7 @@ func (mp mpath) merge(v url.Values) (outPath string, otherValues url.Values, ret
 
 	for _, p := range mp {
 		// log.Printf("p = %q", p)
-		if strings.HasPrefix(p, "/:") {
+		if strings.HasPrefix(p, "/:") || strings.HasPrefix(p, "/*") {
 			pname := p[2:]
 			vlist := v[pname]
 			buf.WriteString("/")
@@ -163,6 +174,20 @@ func (mp mpath) match(p string) (paramValues url.Values, exact, ok bool) {
 
 		// log.Printf("i=%d mpart = %#v", i, mpart)
 
+		// catch-all parameter gets the rest of the path (which may be empty)
+		if strings.HasPrefix(mpart, "*") {
+			pname := mpart[1:]
+			if paramValues == nil {
+				paramValues = make(url.Values, 2)
+			}
+			rest := ""
+			if len(pparts) > i {
+				rest = strings.Join(pparts[i:], "/")
+			}
+			paramValues.Set(pname, rest)
+			return paramValues, true, true
+		}
+
 		// if input path is shorter (fewer parts) than pattern then definitely not a match
 		if len(pparts) <= i {
 			// log.Printf("pparts too short")
@@ -238,3 +263,43 @@ func (mp mpath) match(p string) (paramValues url.Values, exact, ok bool) {
 	// ok = true
 	// return
 }
+
+// partRank returns how specific an mpath part is: catch-all params are less specific than
+// params, which are less specific than static parts.
+func partRank(part string) int {
+	switch {
+	case strings.HasPrefix(part, "/*"):
+		return 0
+	case strings.HasPrefix(part, "/:"):
+		return 1
+	}
+	return 2
+}
+
+// compareSpecificity returns a negative number if mp is a less specific route pattern than o,
+// a positive one if it is more specific and 0 if they are the same.  Parts are compared in turn
+// by partRank, and if all parts compare the same the shorter one is less specific, except that a
+// catch-all is less specific than nothing, e.g. "/user/*rest" is less specific than "/user" since
+// both match "/user" exactly.
+func (mp mpath) compareSpecificity(o mpath) int {
+	// the root has no parts to compare
+	if len(mp) == 1 && mp[0] == "/" {
+		mp = nil
+	}
+	if len(o) == 1 && o[0] == "/" {
+		o = nil
+	}
+	k := 0
+	for ; k < len(mp) && k < len(o); k++ {
+		if d := partRank(mp[k]) - partRank(o[k]); d != 0 {
+			return d
+		}
+	}
+	switch {
+	case k < len(mp) && partRank(mp[k]) == 0:
+		return -1
+	case k < len(o) && partRank(o[k]) == 0:
+		return 1
+	}
+	return len(mp) - len(o)
+}
@@ -30,6 +30,11 @@ func TestMPathParamNames(t *testing.T) {
 		t.Error()
 	}
 
+	mp, _ = parseMpath("/a/:id/*rest")
+	if !reflect.DeepEqual(mp.paramNames(), []string{"id", "rest"}) {
+		t.Error()
+	}
+
 }
 
 func TestMPathParse(t *testing.T) {
@@ -45,6 +50,7 @@ func TestMPathParse(t *testing.T) {
 		{"/:p1/test/:p2", mpath{"/:p1", "/test", "/:p2"}},
 		{"/:p1/:p2", mpath{"/:p1", "/:p2"}},
 		{"/a/b", mpath{"/a", "/b"}},
+		{"/a/*rest", mpath{"/a", "/*rest"}},
 	}
 
 	for _, ti := range tlist {
@@ -59,6 +65,15 @@ func TestMPathParse(t *testing.T) {
 		})
 	}
 
+	for _, in := range []string{"/*rest/a", "/a/*rest/:id", "/*a/*b"} {
+		if _, err := parseMpath(in); err == nil {
+			t.Errorf("%s: expected error for catch-all which is not last", in)
+		}
+	}
+	if err := New(nil).AddRoute("/a/*rest/b", RouteHandlerFunc(func(rm *RouteMatch) {})); err == nil {
+		t.Errorf("expected error from AddRoute")
+	}
+
 }
 
 func TestMPathMergeMatch(t *testing.T) {
@@ -72,6 +87,8 @@ func TestMPathMergeMatch(t *testing.T) {
 		{"/somewhere", mpath{"/:id"}, url.Values{"id": []string{"somewhere"}}},
 		{"/blah/somewhere", mpath{"/blah", "/:id"}, url.Values{"id": []string{"somewhere"}}},
 		{"/blah/somewhere/something", mpath{"/blah", "/:id", "/:id2"}, url.Values{"id": []string{"somewhere"}, "id2": []string{"something"}}},
+		{"/files/a/b/c", mpath{"/files", "/*rest"}, url.Values{"rest": []string{"a/b/c"}}},
+		{"/files/a", mpath{"/files", "/*rest"}, url.Values{"rest": []string{"a"}}},
 	}
 
 	for _, ti := range tlist {
@@ -110,6 +127,9 @@ func TestMPathMatchExact(t *testing.T) {
 		{"/somewhere/1", mpath{"/somewhere", "/:id"}, true, true},
 		{"/somewhere/1/2", mpath{"/somewhere", "/:id"}, false, true},
 		{"/a/v1", mpath{"/a"}, false, true},
+		{"/files", mpath{"/files", "/*rest"}, true, true},
+		{"/files/a/b", mpath{"/files", "/*rest"}, true, true},
+		{"/other/a/b", mpath{"/files", "/*rest"}, false, false},
 	}
 
 	for _, ti := range tlist {
@@ -15,6 +15,19 @@ type Navigator interface {
 	// Push will take any bound parameters and put them into the URL in the appropriate place.
 	// Only works in wasm environment otherwise has no effect.
 	Push(opts ...NavigatorOpt) error
+
+	// Back is the same as Go(-1).
+	Back()
+
+	// Forward is the same as Go(1).
+	Forward()
+
+	// Go moves n entries through the history, negative values go back and positive values go forward.
+	Go(n int)
+
+	// BackOr goes back if there is a previous history entry within the application,
+	// otherwise it navigates to path and query.
+	BackOr(path string, query url.Values, opts ...NavigatorOpt) error
 }
 
 // NavigatorRef embeds a reference to a Navigator and provides a NavigatorSet method.
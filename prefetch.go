@@ -0,0 +1,209 @@
+package vgrouter
+
+import (
+	"net/url"
+	"time"
+)
+
+// DefaultPrefetchTTL is how long resolver results are used for if SetPrefetchTTL is not called.
+const DefaultPrefetchTTL = time.Minute
+
+// RouteResolver provides the data for a route, e.g. by fetching it from an API.
+type RouteResolver interface {
+	// RouteResolve is called in its own goroutine, without the EventEnv lock held, and may block
+	// for as long as it takes to get the data.  The RouteMatch must not be modified.
+	RouteResolve(rm *RouteMatch) (interface{}, error)
+}
+
+// RouteResolverFunc implements RouteResolver as a function.
+type RouteResolverFunc func(rm *RouteMatch) (interface{}, error)
+
+// RouteResolve implements RouteResolver.
+func (f RouteResolverFunc) RouteResolve(rm *RouteMatch) (interface{}, error) { return f(rm) }
+
+// prefetchEntry is the result of calling a resolver for a path and query.
+type prefetchEntry struct {
+	pending bool        // resolver is running
+	data    interface{} // result
+	err     error       // error result
+	expires time.Time   // after this the result is not used again
+	used    bool        // result was provided to a RouteMatch
+	usedSeq int         // processSeq when the result was first provided
+}
+
+// MustAddResolver is like AddResolver but panics upon error.
+func (r *Router) MustAddResolver(path string, res RouteResolver) {
+	err := r.AddResolver(path, res)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// AddResolver sets the resolver for a route path (with params as :param), replacing any earlier one.
+// When a route with the same path matches, the result of the resolver is provided to its handler
+// as RouteMatch.Data (or RouteMatch.DataErr).  If the result is not available yet, because Prefetch
+// was not called or has not finished, the handler is called with RouteMatch.Loading set and the
+// resolver is run.  When it finishes the EventEnv lock is acquired and, if the path and query are
+// still current, they are processed again so the handlers receive the data and a render is requested.
+// Without an EventEnv (see New) they are not processed again and the result is provided the next time
+// the route matches.  Results are used for the TTL set with SetPrefetchTTL.
+func (r *Router) AddResolver(path string, res RouteResolver) error {
+
+	mp, err := parseMpath(path)
+	if err != nil {
+		return err
+	}
+
+	if r.resolvers == nil {
+		r.resolvers = make(map[string]RouteResolver)
+	}
+	r.resolvers[mp.String()] = res
+
+	return nil
+}
+
+// SetPrefetchTTL sets how long the results of resolvers are used for after they are obtained.
+// A result is always used at least once, so with a TTL of 0 each result is only used for the
+// navigation it was obtained for (or the first one after Prefetch).  The default is DefaultPrefetchTTL.
+func (r *Router) SetPrefetchTTL(ttl time.Duration) {
+	r.prefetchTTL = ttl
+}
+
+// Prefetch indicates that path and query are likely to be navigated to soon.  The routes which
+// match are found in the same way as for navigation, and their loaders (see AddRouteLoader) and
+// resolvers (see AddResolver) are started if they haven't been already, so the handler and data
+// can be provided without waiting when navigation happens.  The path is without the path prefix,
+// as with Navigate.  Prefetch does not wait for anything to load.  It must be called with the
+// EventEnv lock held, as event handlers are.
+func (r *Router) Prefetch(path string, query url.Values) {
+
+	for _, re := range r.rlist {
+
+		rm := r.matchRoute(re.mpath, path, query, nil)
+		if rm == nil {
+			continue
+		}
+
+		if re.load != nil {
+			re.load.start()
+		}
+
+		if res := r.resolvers[rm.RoutePath]; res != nil {
+			r.prefetchMu.Lock()
+			e := r.prefetchEntry(prefetchKey(rm), 0)
+			if e == nil {
+				r.startResolve(rm, res)
+			}
+			r.prefetchMu.Unlock()
+		}
+	}
+}
+
+// resolveData sets rm.Data from the prefetch cache if available, otherwise it sets rm.Loading and
+// runs the resolver (if it isn't already running).
+func (r *Router) resolveData(rm *RouteMatch, res RouteResolver) {
+
+	r.prefetchMu.Lock()
+	defer r.prefetchMu.Unlock()
+
+	e := r.prefetchEntry(prefetchKey(rm), r.processSeq)
+	if e == nil {
+		e = r.startResolve(rm, res)
+	}
+
+	if e.pending {
+		rm.Loading = true
+		return
+	}
+
+	rm.Data, rm.DataErr = e.data, e.err
+	if !e.used {
+		e.used, e.usedSeq = true, r.processSeq
+	}
+}
+
+// prefetchEntry returns the cache entry for key if it is pending or can still be used.
+// An expired result can still be used if it hasn't been yet or was first used by processing
+// with the same seq (see processSeq), zero means none.  It must be called with prefetchMu held.
+func (r *Router) prefetchEntry(key string, seq int) *prefetchEntry {
+	e := r.prefetchCache[key]
+	if e == nil || e.pending || !e.used || (seq != 0 && e.usedSeq == seq) || r.clock().Before(e.expires) {
+		return e
+	}
+	delete(r.prefetchCache, key)
+	return nil
+}
+
+// startResolve runs res for rm in a new goroutine and returns the pending cache entry.
+// It must be called with prefetchMu held.
+func (r *Router) startResolve(rm *RouteMatch, res RouteResolver) *prefetchEntry {
+
+	key := prefetchKey(rm)
+	e := &prefetchEntry{pending: true}
+	if r.prefetchCache == nil {
+		r.prefetchCache = make(map[string]*prefetchEntry)
+	}
+	r.prefetchCache[key] = e
+
+	rmc := *rm
+	rmc.Loading, rmc.Data, rmc.DataErr = false, nil, nil
+
+	go func() {
+
+		data, err := res.RouteResolve(&rmc)
+
+		if r.eventEnv != nil {
+			r.eventEnv.Lock()
+		}
+
+		r.prefetchMu.Lock()
+		e.pending, e.data, e.err = false, data, err
+		e.expires = r.clock().Add(r.prefetchTTL)
+		r.prefetchMu.Unlock()
+
+		if r.eventEnv == nil {
+			// without an EventEnv there is no lock for the router state, so only the cache is updated
+			return
+		}
+
+		// if we are still there, process again so the handlers get the data
+		current := false
+		if mp, err := parseMpath(rmc.RoutePath); err == nil {
+			cur := r.matchRoute(mp, r.curPath, r.curQuery, nil)
+			current = cur != nil && prefetchKey(cur) == key
+		}
+		if current {
+			r.reprocess()
+		}
+
+		if current {
+			r.eventEnv.UnlockRender()
+		} else {
+			r.eventEnv.UnlockOnly()
+		}
+	}()
+
+	return e
+}
+
+// reprocess processes the current path and query again without changing the previous ones.
+// It is the same navigation, so processSeq is not changed and results already used for it can be
+// used again even if expired (otherwise resolvers for different routes would keep expiring each other's).
+func (r *Router) reprocess() {
+	prevPath, prevQuery := r.prevPath, r.prevQuery
+	r.processSeq-- // incremented again by process
+	r.process(r.curPath, r.curQuery)
+	r.prevPath, r.prevQuery = prevPath, prevQuery
+}
+
+// prefetchKey returns the cache key for the resolver results for rm.
+func prefetchKey(rm *RouteMatch) string {
+	return rm.Locale + " " + rm.RoutePath + " " + rm.Path + "?" + rm.Params.Encode()
+}
+
+func (r *Router) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,175 @@
+package vgrouter
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrefetch(t *testing.T) {
+
+	ee := &testEventEnv{renders: make(chan bool, 10)}
+	r := New(ee)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	r.SetPrefetchTTL(time.Minute)
+
+	var handled []string
+	r.MustAddRoute("/users/:id", RouteHandlerFunc(func(rm *RouteMatch) {
+		switch {
+		case rm.Loading:
+			handled = append(handled, "loading "+rm.Path)
+		case rm.DataErr != nil:
+			handled = append(handled, "error "+rm.DataErr.Error())
+		default:
+			handled = append(handled, rm.Data.(string))
+		}
+	}))
+
+	results := make(chan error)
+	calls := 0
+	r.MustAddResolver("/users/:id", RouteResolverFunc(func(rm *RouteMatch) (interface{}, error) {
+		calls++
+		if err := <-results; err != nil {
+			return nil, err
+		}
+		return "user " + rm.Params.Get("id") + " " + rm.Params.Get("tab"), nil
+	}))
+
+	// prefetched data is available immediately, no render since the path is not current
+	ee.Lock()
+	r.Prefetch("/users/1", url.Values{"tab": {"a"}})
+	ee.Unlock()
+	results <- nil
+	waitResolved(r, " /users/:id /users/1?id=1&tab=a")
+	ee.Lock()
+	r.process("/users/1", url.Values{"tab": {"a"}})
+	ee.Unlock()
+
+	// without prefetching the handler is called while loading and again with the data
+	ee.Lock()
+	r.process("/users/2", nil)
+	ee.Unlock()
+	results <- nil
+	<-ee.renders
+
+	// errors are provided too
+	ee.Lock()
+	r.process("/users/3", nil)
+	ee.Unlock()
+	results <- errors.New("not found")
+	<-ee.renders
+
+	// cached until the TTL is up
+	ee.Lock()
+	r.process("/users/1", url.Values{"tab": {"a"}})
+	now = now.Add(2 * time.Minute)
+	r.process("/users/1", url.Values{"tab": {"a"}})
+	ee.Unlock()
+	results <- nil
+	<-ee.renders
+
+	expected := []string{
+		"user 1 a",
+		"loading /users/2", "user 2 ",
+		"loading /users/3", "error not found",
+		"user 1 a", "loading /users/1", "user 1 a",
+	}
+	if !reflect.DeepEqual(handled, expected) {
+		t.Errorf("expected handled %v, got %v", expected, handled)
+	}
+	if calls != 4 {
+		t.Errorf("expected 4 calls to resolver, got %d", calls)
+	}
+	if len(ee.renders) != 0 {
+		t.Errorf("unexpected renders: %d", len(ee.renders))
+	}
+
+	// prefetch starts route loaders as well
+	loads := make(chan string, 1)
+	r.MustAddRouteLoader("/admin", RouteLoaderFunc(func(path string) (RouteHandler, error) {
+		loads <- path
+		return RouteHandlerFunc(func(rm *RouteMatch) {}), nil
+	}))
+	ee.Lock()
+	r.Prefetch("/admin", nil)
+	ee.Unlock()
+	if p := <-loads; p != "/admin" {
+		t.Errorf("expected load of /admin, got %q", p)
+	}
+	// /admin is not the current path, so it is not processed again and there is no render
+	ee.Lock()
+	if len(ee.renders) != 0 {
+		t.Errorf("unexpected renders: %d", len(ee.renders))
+	}
+	ee.Unlock()
+
+}
+
+// waitResolved waits for the resolver for key to finish.
+func waitResolved(r *Router, key string) {
+	for {
+		r.prefetchMu.Lock()
+		e := r.prefetchCache[key]
+		done := e != nil && !e.pending
+		r.prefetchMu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPrefetchOverlappingResolvers(t *testing.T) {
+
+	ee := &testEventEnv{renders: make(chan bool, 10)}
+	r := New(ee)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	r.SetPrefetchTTL(0)
+
+	data := make(map[string]interface{})
+	h := RouteHandlerFunc(func(rm *RouteMatch) { data[rm.RoutePath] = rm.Data })
+	r.MustAddRoute("/u", h)
+	r.MustAddRoute("/u/:id", h)
+
+	var mu sync.Mutex
+	calls := 0
+	res := RouteResolverFunc(func(rm *RouteMatch) (interface{}, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return "data " + rm.RoutePath, nil
+	})
+	r.MustAddResolver("/u", res)
+	r.MustAddResolver("/u/:id", res)
+
+	// each result arriving processes the path again, which must not expire the other one
+	ee.Lock()
+	r.process("/u/1", nil)
+	ee.Unlock()
+	<-ee.renders
+	<-ee.renders
+
+	time.Sleep(20 * time.Millisecond)
+	ee.Lock()
+	defer ee.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 2 {
+		t.Errorf("expected 2 calls to resolvers, got %d", calls)
+	}
+	if len(ee.renders) != 0 {
+		t.Errorf("unexpected renders: %d", len(ee.renders))
+	}
+	expected := map[string]interface{}{"/u": "data /u", "/u/:id": "data /u/:id"}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected data %v, got %v", expected, data)
+	}
+
+}
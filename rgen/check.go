@@ -0,0 +1,127 @@
+package rgen
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Diff describes a generated file which is out of date on disk.
+type Diff struct {
+	Path          string   // absolute path of the file
+	Missing       bool     // the file does not exist but would be written
+	Removed       bool     // the file exists but would be removed
+	AddedRoutes   []string // routes in the generated file which are not in the file on disk
+	RemovedRoutes []string // routes in the file on disk which are not in the generated file
+}
+
+// Check does the route generation in memory and compares the result with the files on disk,
+// returning a Diff for each file that is stale.  Nothing is written.  A file can be stale
+// without any routes being added or removed, e.g. if the generator options changed.
+func (g *Generator) Check() ([]Diff, error) {
+
+	files, err := g.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Diff
+	for _, f := range files {
+
+		existing, err := ioutil.ReadFile(f.Path)
+		if os.IsNotExist(err) {
+			if f.Content != nil {
+				ret = append(ret, Diff{Path: f.Path, Missing: true, AddedRoutes: routeKeys(f.Content)})
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if f.Content == nil {
+			ret = append(ret, Diff{Path: f.Path, Removed: true, RemovedRoutes: routeKeys(existing)})
+			continue
+		}
+
+		if bytes.Equal(existing, f.Content) {
+			continue
+		}
+
+		d := Diff{Path: f.Path}
+		oldKeys, newKeys := routeKeys(existing), routeKeys(f.Content)
+		d.AddedRoutes = subtractStrings(newKeys, oldKeys)
+		d.RemovedRoutes = subtractStrings(oldKeys, newKeys)
+		ret = append(ret, d)
+	}
+
+	return ret, nil
+}
+
+// routeKeys returns the sorted keys of the vgRouteMap declared in the Go source src.
+// Nil is returned if it cannot be found.
+func routeKeys(src []byte) []string {
+
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil
+	}
+
+	var ret []string
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if name.Name != "vgRouteMap" || i >= len(vs.Values) {
+					continue
+				}
+				cl, ok := vs.Values[i].(*ast.CompositeLit)
+				if !ok {
+					continue
+				}
+				for _, elt := range cl.Elts {
+					kv, ok := elt.(*ast.KeyValueExpr)
+					if !ok {
+						continue
+					}
+					bl, ok := kv.Key.(*ast.BasicLit)
+					if !ok || bl.Kind != token.STRING {
+						continue
+					}
+					k, err := strconv.Unquote(bl.Value)
+					if err != nil {
+						continue
+					}
+					ret = append(ret, k)
+				}
+			}
+		}
+	}
+
+	sort.Strings(ret)
+	return ret
+}
+
+// subtractStrings returns the elements of a which are not in b.
+func subtractStrings(a, b []string) []string {
+	bm := make(map[string]bool, len(b))
+	for _, s := range b {
+		bm[s] = true
+	}
+	var ret []string
+	for _, s := range a {
+		if !bm[s] {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,180 @@
+package rgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFile is the name of the configuration file read from the directory by vgrgen.
+const ConfigFile = "vgrouter.json"
+
+// Config is the contents of a configuration file, which lets the Generator be customized without
+// writing Go code.  Example:
+//
+//	{
+//		"include": ["*.vugu", "*.page.html"],
+//		"exclude": ["admin/**", "*-draft.vugu"],
+//		"paths": {"about-us.vugu": "/about", "user/_id/settings.vugu": "/prefs"},
+//		"prefix": "/app",
+//		"output": "routes_gen.go",
+//		"trailingSlash": true
+//	}
+//
+// Patterns use path.Match syntax.  A pattern without a slash is matched against the file name in
+// any directory, one with a slash is matched against the path relative to the directory being
+// generated, and a "**" part matches any number of directories.
+type Config struct {
+	Include       []string          `json:"include"`       // files to include, if empty DefaultIncludeFunc is used
+	Exclude       []string          `json:"exclude"`       // files to exclude even if they match Include
+	Paths         map[string]string `json:"paths"`         // see Generator.SetPathMap
+	Prefix        string            `json:"prefix"`        // see Generator.SetPrefix
+	Output        string            `json:"output"`        // see Generator.SetOutputFile
+	TrailingSlash bool              `json:"trailingSlash"` // see Generator.SetTrailingSlash
+}
+
+// ReadConfig reads the ConfigFile from dir.  If the file does not exist nil is returned with no error.
+// Unknown fields and invalid patterns are reported as errors.
+func ReadConfig(dir string) (*Config, error) {
+	return ReadConfigFile(filepath.Join(dir, ConfigFile), true)
+}
+
+// ReadConfigFile reads a configuration file from p.  If optional is true and the file does not
+// exist nil is returned with no error.  Only JSON is supported, an error is returned for YAML files.
+func ReadConfigFile(p string, optional bool) (*Config, error) {
+
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".yaml", ".yml":
+		return nil, fmt.Errorf("error reading %q: only JSON configuration files are supported", p)
+	}
+
+	f, err := os.Open(p)
+	if optional && os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c Config
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&c)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q: %w", p, err)
+	}
+
+	for _, pattern := range append(append([]string(nil), c.Include...), c.Exclude...) {
+		err = validGlob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error in %q: pattern %q: %w", p, pattern, err)
+		}
+	}
+
+	for file, rpath := range c.Paths {
+		if !strings.HasPrefix(rpath, "/") {
+			return nil, fmt.Errorf("error in %q: path %q for %q must start with a slash", p, rpath, file)
+		}
+	}
+
+	return &c, nil
+}
+
+// Apply sets the options from c on g.
+func (c *Config) Apply(g *Generator) *Generator {
+
+	if len(c.Include) > 0 || len(c.Exclude) > 0 {
+		g.SetIncludeFunc(c.IncludeFunc)
+	}
+	if c.Paths != nil {
+		g.SetPathMap(c.Paths)
+	}
+	if c.Prefix != "" {
+		g.SetPrefix(c.Prefix)
+	}
+	if c.Output != "" {
+		g.SetOutputFile(c.Output)
+	}
+	if c.TrailingSlash {
+		g.SetTrailingSlash(true)
+	}
+
+	return g
+}
+
+// IncludeFunc is an include function (see Generator.SetIncludeFunc) which applies the Include and
+// Exclude patterns.
+func (c *Config) IncludeFunc(dirPath, fileName string) bool {
+
+	p := path.Join(dirPath, fileName)
+
+	included := len(c.Include) == 0 && DefaultIncludeFunc(dirPath, fileName)
+	for _, pattern := range c.Include {
+		if matchGlob(pattern, p) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+
+	for _, pattern := range c.Exclude {
+		if matchGlob(pattern, p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validGlob returns an error if pattern is malformed.
+func validGlob(pattern string) error {
+	for _, part := range strings.Split(pattern, "/") {
+		if _, err := path.Match(part, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchGlob returns true if the slash separated path p matches pattern, see Config.
+func matchGlob(pattern, p string) bool {
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(p))
+		return ok
+	}
+
+	return matchParts(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(p, "/"))
+}
+
+func matchParts(pparts, parts []string) bool {
+
+	for len(pparts) > 0 {
+
+		if pparts[0] == "**" {
+			// try matching the rest of the pattern at each remaining position
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(pparts[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pparts[0], parts[0]); !ok {
+			return false
+		}
+		pparts, parts = pparts[1:], parts[1:]
+	}
+
+	return len(parts) == 0
+}
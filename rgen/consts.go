@@ -0,0 +1,135 @@
+package rgen
+
+import (
+	"fmt"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// constRoute describes a route constant (and for parameterized routes a URL function) to be generated.
+type constRoute struct {
+	Path  string // full route path, e.g. "/user/:id"
+	Ident string // identifier suffix, e.g. "UserId" for RouteUserId and URLUserId
+	Args  string // URL function arguments, e.g. "id string", empty if no params
+	Expr  string // URL function return expression
+}
+
+// constRoutes returns the route constants for df and (if recursive) all of its sub-directories,
+// with paths relative to prefix (and the prefix set with SetPrefix).  An error is returned if two
+// routes result in the same identifier.
+func (g *Generator) constRoutes(df *dirf, prefix string) ([]constRoute, error) {
+
+	var ret []constRoute
+	var walk func(df *dirf, prefix string)
+	walk = func(df *dirf, prefix string) {
+		for _, r := range df.routes {
+			p := path.Clean(prefix + r.Path)
+			full := path.Clean(g.prefix + p)
+			if g.slash && full != "/" && path.Base(full)[0] != '*' {
+				full += "/"
+			}
+			// the identifier doesn't include the prefix set with SetPrefix
+			cr := makeConstRoute(full)
+			cr.Ident = makeConstRoute(p).Ident
+			ret = append(ret, cr)
+		}
+		if !g.recursive {
+			return
+		}
+		for _, name := range sortedKeys(df.subdirs) {
+			walk(df.subdirs[name], prefix+g.dirPath(name))
+		}
+	}
+	walk(df, prefix)
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Path < ret[j].Path })
+
+	seen := make(map[string]string, len(ret))
+	for _, cr := range ret {
+		if p, ok := seen[cr.Ident]; ok {
+			return nil, fmt.Errorf("routes %q and %q both result in the identifier Route%s", p, cr.Path, cr.Ident)
+		}
+		seen[cr.Ident] = cr.Path
+	}
+
+	return ret, nil
+}
+
+// makeConstRoute works out the identifier and URL function for route path p.
+func makeConstRoute(p string) constRoute {
+
+	ret := constRoute{Path: p}
+
+	var ident strings.Builder
+	var args, expr []string
+	static := ""
+	for _, part := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+
+		if part == "" {
+			continue
+		}
+
+		ident.WriteString(identPart(part))
+
+		if part[0] != ':' && part[0] != '*' {
+			static += "/" + part
+			continue
+		}
+
+		arg := part[1:]
+		if !token.IsIdentifier(arg) || token.IsKeyword(arg) {
+			arg = fmt.Sprintf("param%d", len(args))
+		}
+		// a param name used more than once (e.g. "/:a/x/:a") is numbered after the first use
+		for base, n := arg, 2; containsString(args, arg); n++ {
+			arg = fmt.Sprintf("%s%d", base, n)
+		}
+		args = append(args, arg)
+
+		expr = append(expr, strconv.Quote(static+"/"), arg)
+		static = ""
+	}
+
+	if len(expr) == 0 && static == "" || p != "/" && strings.HasSuffix(p, "/") {
+		static += "/"
+	}
+	if static != "" {
+		expr = append(expr, strconv.Quote(static))
+	}
+
+	ret.Ident = ident.String()
+	if ret.Ident == "" {
+		ret.Ident = "Index"
+	}
+	if len(args) > 0 {
+		ret.Args = strings.Join(args, ", ") + " string"
+	}
+	ret.Expr = strings.Join(expr, " + ")
+
+	return ret
+}
+
+// identPart converts a path part into an exported identifier fragment, e.g. "page-a" becomes "PageA".
+func identPart(s string) string {
+	var sb strings.Builder
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		rs := []rune(w)
+		rs[0] = unicode.ToUpper(rs[0])
+		sb.WriteString(string(rs))
+	}
+	return sb.String()
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]*dirf) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
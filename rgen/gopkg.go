@@ -0,0 +1,348 @@
+package rgen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// routeDirective is the doc comment prefix used to specify the route path for a type.
+const routeDirective = "//vgrouter:route"
+
+// goPkg is the information we need from the Go source files in a directory.
+type goPkg struct {
+	typeNames  map[string]bool // names of all top level types declared
+	components []goComponent   // types which are Builders or have a route directive, sorted by name
+}
+
+// goComponent is a type found in the Go source which may be used for a route.
+type goComponent struct {
+	typeName  string
+	builder   bool              // true if the type has a Build(*vugu.BuildIn) *vugu.BuildOut method
+	directive string            // path from the route directive, "-" to exclude, empty if no directive
+	meta      map[string]string // from meta directives, nil if none
+}
+
+// goFileFilter returns a filter for the Go files we read in dir, ignoring tests, files the Go tool
+// ignores, files excluded by build constraints (see buildContext) and our own output file (which may be stale).
+func (g *Generator) goFileFilter(dir string) func(fi os.FileInfo) bool {
+	ctx := buildContext()
+	return func(fi os.FileInfo) bool {
+		if !g.isGoFile(fi.Name()) {
+			return false
+		}
+		// files whose constraints can't be read are kept so the parser reports the problem
+		ok, err := ctx.MatchFile(dir, fi.Name())
+		return ok || err != nil
+	}
+}
+
+// buildContext returns the context used to evaluate build constraints.  It is build.Default,
+// with GOOS and GOARCH set to js and wasm (the usual target for components) unless either is
+// set in the environment.
+func buildContext() build.Context {
+	ctx := build.Default
+	if os.Getenv("GOOS") == "" && os.Getenv("GOARCH") == "" {
+		ctx.GOOS, ctx.GOARCH = "js", "wasm"
+	}
+	return ctx
+}
+
+// isGoFile returns true if n is the name of a Go file we read, see goFileFilter.
+func (g *Generator) isGoFile(n string) bool {
+
+	outputFile := g.outputFile
+	if outputFile == "" {
+		outputFile = DefaultOutputFile
+	}
+
+	return strings.HasSuffix(n, ".go") &&
+		!strings.HasSuffix(n, "_test.go") &&
+		!strings.HasPrefix(n, "_") &&
+		!strings.HasPrefix(n, ".") &&
+		n != outputFile &&
+		n != legacyOutputFile
+}
+
+// goPackageName returns the package name declared by the Go files in dir, or an empty
+// string if there are none.  An error is returned if the files declare more than one package.
+func (g *Generator) goPackageName(dir string) (string, error) {
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, g.goFileFilter(dir), parser.PackageClauseOnly)
+	if err != nil {
+		return "", err
+	}
+
+	if len(pkgs) > 1 {
+		names := make([]string, 0, len(pkgs))
+		for name := range pkgs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("found multiple packages in %q: %s", dir, strings.Join(names, ", "))
+	}
+
+	for name := range pkgs {
+		return name, nil
+	}
+
+	return "", nil
+}
+
+// readGoPkg parses the Go files in dir (see goFileFilter).
+func (g *Generator) readGoPkg(dir string) (*goPkg, error) {
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, g.goFileFilter(dir), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &goPkg{
+		typeNames: make(map[string]bool),
+	}
+
+	builders := make(map[string]bool)
+	directives := make(map[string]string)
+	metas := make(map[string]map[string]string)
+
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			vuguName := vuguImportName(f)
+			for _, decl := range f.Decls {
+
+				switch d := decl.(type) {
+
+				case *ast.GenDecl:
+					if d.Tok != token.TYPE {
+						continue
+					}
+					for _, spec := range d.Specs {
+						ts := spec.(*ast.TypeSpec)
+						ret.typeNames[ts.Name.Name] = true
+						doc := ts.Doc
+						if doc == nil && len(d.Specs) == 1 {
+							doc = d.Doc
+						}
+						if dv, ok := readRouteDirective(doc); ok {
+							directives[ts.Name.Name] = dv
+						}
+						meta, err := readMetaDirective(doc)
+						if err != nil {
+							return nil, fmt.Errorf("%s: %w", fset.Position(doc.Pos()), err)
+						}
+						if meta != nil {
+							metas[ts.Name.Name] = meta
+						}
+					}
+
+				case *ast.FuncDecl:
+					if d.Name.Name != "Build" || d.Recv == nil || len(d.Recv.List) != 1 ||
+						d.Type.Params.NumFields() != 1 || d.Type.Results.NumFields() != 1 ||
+						!isVuguPtr(d.Type.Params.List[0].Type, vuguName, "BuildIn") ||
+						!isVuguPtr(d.Type.Results.List[0].Type, vuguName, "BuildOut") {
+						continue
+					}
+					rt := d.Recv.List[0].Type
+					if se, ok := rt.(*ast.StarExpr); ok {
+						rt = se.X
+					}
+					if id, ok := rt.(*ast.Ident); ok {
+						builders[id.Name] = true
+					}
+
+				}
+			}
+		}
+	}
+
+	for name := range ret.typeNames {
+		dv, hasDirective := directives[name]
+		if !builders[name] && !hasDirective {
+			continue
+		}
+		ret.components = append(ret.components, goComponent{
+			typeName:  name,
+			builder:   builders[name],
+			directive: dv,
+			meta:      metas[name],
+		})
+	}
+	sort.Slice(ret.components, func(i, j int) bool { return ret.components[i].typeName < ret.components[j].typeName })
+
+	return ret, nil
+}
+
+// vuguImportPath is the import path of the package which declares BuildIn and BuildOut.
+const vuguImportPath = "github.com/vugu/vugu"
+
+// vuguImportName returns the name f uses for the vugu package, "." for a dot import
+// or an empty string if f does not import it.
+func vuguImportName(f *ast.File) string {
+	for _, imp := range f.Imports {
+		if imp.Path.Value != strconv.Quote(vuguImportPath) {
+			continue
+		}
+		if imp.Name != nil {
+			if imp.Name.Name == "_" {
+				return ""
+			}
+			return imp.Name.Name
+		}
+		return "vugu"
+	}
+	return ""
+}
+
+// isVuguPtr returns true if expr is a pointer to the type typeName from the vugu package,
+// imported as vuguName (see vuguImportName).
+func isVuguPtr(expr ast.Expr, vuguName, typeName string) bool {
+	se, ok := expr.(*ast.StarExpr)
+	if !ok || vuguName == "" {
+		return false
+	}
+	switch x := se.X.(type) {
+	case *ast.Ident:
+		return vuguName == "." && x.Name == typeName
+	case *ast.SelectorExpr:
+		id, ok := x.X.(*ast.Ident)
+		return ok && id.Name == vuguName && x.Sel.Name == typeName
+	}
+	return false
+}
+
+// readRouteDirective returns the value of the route directive in doc, if present.
+func readRouteDirective(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, c := range doc.List {
+		if c.Text == routeDirective || strings.HasPrefix(c.Text, routeDirective+" ") {
+			v := strings.TrimSpace(strings.TrimPrefix(c.Text, routeDirective))
+			if v == "" || v == "-" {
+				return v, v != ""
+			}
+			return path.Clean("/" + v), true
+		}
+	}
+	return "", false
+}
+
+// addGoRoutes updates the routes in df with the components and directives from the Go source in dir.
+func (g *Generator) addGoRoutes(df *dirf, dir string) error {
+
+	gp, err := g.readGoPkg(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range gp.components {
+
+		idx := -1
+		for i := range df.routes {
+			if df.routes[i].TypeName == c.typeName {
+				idx = i
+				break
+			}
+		}
+
+		switch {
+		case c.directive == "-":
+			if idx >= 0 {
+				df.routes = append(df.routes[:idx], df.routes[idx+1:]...)
+			}
+		case idx >= 0:
+			if c.directive != "" {
+				df.routes[idx].Path = c.directive
+			}
+			// Go source metadata takes precedence over that in the file
+			df.routes[idx].Meta = mergeMeta(df.routes[idx].Meta, c.meta)
+		case c.directive != "":
+			df.routes = append(df.routes, dirRoute{Path: c.directive, TypeName: c.typeName, Meta: c.meta})
+		case ast.IsExported(c.typeName):
+			df.routes = append(df.routes, dirRoute{Path: DefaultTypePathFunc(c.typeName), TypeName: c.typeName, Meta: c.meta})
+		}
+	}
+
+	return nil
+}
+
+// DefaultTypePathFunc returns the path for a type found when parsing Go source, the name is
+// converted to lower case with dashes between words and a slash prepended.  E.g. "UserList"
+// returns "/user-list" and "HTMLPage" returns "/html-page".  The special case of Index returns "/".
+func DefaultTypePathFunc(typeName string) string {
+
+	if typeName == "Index" {
+		return "/"
+	}
+
+	rs := []rune(typeName)
+	var sb strings.Builder
+	sb.WriteString("/")
+	for i, r := range rs {
+		if i > 0 && unicode.IsUpper(r) &&
+			(!unicode.IsUpper(rs[i-1]) || (i+1 < len(rs) && unicode.IsLower(rs[i+1]))) {
+			sb.WriteString("-")
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+// verifyTypes returns an error if any of the types for the files in df (and its
+// sub-directories) are not declared in the Go source in the corresponding directory.
+func (g *Generator) verifyTypes(df *dirf) error {
+
+	if len(df.routes) > 0 {
+		dir := filepath.Join(g.dir, df.path)
+		gp, err := g.readGoPkg(dir)
+		if err != nil {
+			return err
+		}
+		var missing []string
+		for _, r := range df.routes {
+			if gp.typeNames[r.TypeName] {
+				continue
+			}
+			if r.FileName != "" {
+				missing = append(missing, r.TypeName+" (from "+r.FileName+")")
+			} else {
+				missing = append(missing, r.TypeName)
+			}
+		}
+		if len(missing) > 0 {
+			return ErrMissingTypes{Dir: dir, Types: missing}
+		}
+	}
+
+	for _, name := range sortedKeys(df.subdirs) {
+		err := g.verifyTypes(df.subdirs[name])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ErrMissingTypes is returned when type checking is enabled and the type for a route is not
+// declared in the package.  This usually means vugugen needs to be run first.
+type ErrMissingTypes struct {
+	Dir   string   // directory of the package
+	Types []string // the missing types
+}
+
+// Error implements error.
+func (e ErrMissingTypes) Error() string {
+	return "types not found in " + e.Dir + " (does vugugen need to be run first?): " + strings.Join(e.Types, ", ")
+}
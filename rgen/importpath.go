@@ -0,0 +1,266 @@
+package rgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrImportPath is returned when the import path for the directory being generated cannot be
+// determined automatically.  It can be specified with SetPackageName instead.
+type ErrImportPath struct {
+	Dir    string // the directory
+	Reason string // why the import path could not be determined
+}
+
+// Error implements error.
+func (e ErrImportPath) Error() string {
+	return fmt.Sprintf("unable to determine import path for %q: %s (specify it with -p or SetPackageName)", e.Dir, e.Reason)
+}
+
+// guessImportPath returns the import path for dir.  The module is the one whose go.mod is in
+// dir or the closest parent directory, so nested modules are handled.  If a go.work file is in
+// effect (see findWorkFile) the module must be one it uses.  Directories under the module's vendor
+// directory have the vendored import path.  If dir is not in a module, GOPATH is checked.
+func guessImportPath(dir string) (string, error) {
+
+	modDir, err := findModuleDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if modDir == "" {
+		if p, ok := gopathImportPath(dir); ok {
+			return p, nil
+		}
+		return "", ErrImportPath{Dir: dir, Reason: "no go.mod file found in it or any parent directory"}
+	}
+
+	f, err := os.Open(filepath.Join(modDir, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	modPath, err := readModuleEntry(f)
+	if err != nil {
+		return "", ErrImportPath{Dir: dir, Reason: fmt.Sprintf("%v in %q", err, modDir)}
+	}
+
+	workFile, err := findWorkFile(dir)
+	if err != nil {
+		return "", err
+	}
+	if workFile != "" {
+		uses, err := readWorkUses(workFile)
+		if err != nil {
+			return "", err
+		}
+		if !containsString(uses, modDir) {
+			return "", ErrImportPath{Dir: dir, Reason: fmt.Sprintf("module %s in %q is not used by %q", modPath, modDir, workFile)}
+		}
+	}
+
+	rel, err := filepath.Rel(modDir, dir)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+
+	switch {
+	case rel == ".":
+		return modPath, nil
+	case rel == "vendor":
+		return "", ErrImportPath{Dir: dir, Reason: "the vendor directory is not a package"}
+	case strings.HasPrefix(rel, "vendor/"):
+		return strings.TrimPrefix(rel, "vendor/"), nil
+	}
+
+	return modPath + "/" + rel, nil
+}
+
+// findModuleDir returns dir or the closest parent of it which contains a go.mod file,
+// or an empty string if there is none.
+func findModuleDir(dir string) (string, error) {
+	return findUp(dir, "go.mod")
+}
+
+// findWorkFile returns the go.work file which applies to dir, or an empty string if none.
+// As with the go command, the GOWORK environment variable can be used to specify the file or
+// "off" to disable workspace mode, otherwise dir and its parents are searched.
+func findWorkFile(dir string) (string, error) {
+
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return "", nil
+	case "":
+	default:
+		return filepath.Abs(gowork)
+	}
+
+	wdir, err := findUp(dir, "go.work")
+	if err != nil || wdir == "" {
+		return "", err
+	}
+	return filepath.Join(wdir, "go.work"), nil
+}
+
+// findUp returns the first of dir and its parents which contains a file called name,
+// or an empty string if there is none.
+func findUp(dir, name string) (string, error) {
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err == nil && !fi.IsDir() {
+			return dir, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir { // we hit the root dir
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readWorkUses returns the absolute module directories from the use directives in a go.work file.
+func readWorkUses(p string) ([]string, error) {
+
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	inBlock := false
+	for _, line := range bytes.Split(b, []byte("\n")) {
+
+		if i := bytes.Index(line, slashSlash); i >= 0 {
+			line = line[:i]
+		}
+		s := strings.TrimSpace(string(line))
+
+		switch {
+		case inBlock && s == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case s == "use (" || s == "use(":
+			inBlock = true
+			continue
+		case strings.HasPrefix(s, "use ") || strings.HasPrefix(s, "use\t"):
+			s = strings.TrimSpace(s[len("use"):])
+		default:
+			continue
+		}
+
+		if s == "" {
+			continue
+		}
+		if s[0] == '"' || s[0] == '`' {
+			s, err = strconv.Unquote(s)
+			if err != nil {
+				return nil, fmt.Errorf("malformed use directive in %q: %w", p, err)
+			}
+		}
+		if !filepath.IsAbs(s) {
+			s = filepath.Join(filepath.Dir(p), s)
+		}
+		ret = append(ret, filepath.Clean(s))
+	}
+
+	return ret, nil
+}
+
+// gopathImportPath returns the import path for dir if it is under the src directory of a GOPATH entry.
+func gopathImportPath(dir string) (string, bool) {
+	for _, gp := range filepath.SplitList(build.Default.GOPATH) {
+		rel, err := filepath.Rel(filepath.Join(gp, "src"), dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return filepath.ToSlash(rel), true
+	}
+	return "", false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func readModuleEntry(r io.Reader) (string, error) {
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	ret := modulePath(b)
+	if ret == "" {
+		return "", errors.New("unable to determine module path from go.mod")
+	}
+
+	return ret, nil
+}
+
+// shamelessly stolen from: https://github.com/golang/vgo/blob/master/vendor/cmd/go/internal/modfile/read.go#L837
+// ModulePath returns the module path from the gomod file text.
+// If it cannot find a module path, it returns an empty string.
+// It is tolerant of unrelated problems in the go.mod file.
+func modulePath(mod []byte) string {
+	for len(mod) > 0 {
+		line := mod
+		mod = nil
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line, mod = line[:i], line[i+1:]
+		}
+		if i := bytes.Index(line, slashSlash); i >= 0 {
+			line = line[:i]
+		}
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, moduleStr) {
+			continue
+		}
+		line = line[len(moduleStr):]
+		n := len(line)
+		line = bytes.TrimSpace(line)
+		if len(line) == n || len(line) == 0 {
+			continue
+		}
+
+		if line[0] == '"' || line[0] == '`' {
+			p, err := strconv.Unquote(string(line))
+			if err != nil {
+				return "" // malformed quoted string or multiline module path
+			}
+			return p
+		}
+
+		return string(line)
+	}
+	return "" // missing module path
+}
+
+var (
+	slashSlash = []byte("//")
+	moduleStr  = []byte("module")
+)
@@ -0,0 +1,88 @@
+package rgen
+
+import (
+	"fmt"
+	"go/ast"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// metaDirective is the doc comment prefix used to specify route metadata for a type.
+const metaDirective = "//vgrouter:meta"
+
+// metaCommentRE matches a metadata comment in a .vugu file, e.g. <!-- vgrouter: title="Users" -->
+var metaCommentRE = regexp.MustCompile(`<!--\s*vgrouter:((?s).*?)-->`)
+
+// metaAttrRE matches a single key=value pair, the value may be double or single quoted.
+var metaAttrRE = regexp.MustCompile(`^\s*([A-Za-z_][\w.-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"']+))`)
+
+// readFileMeta returns the route metadata from the comments in the file at p, see Generator.
+// Later values replace earlier ones with the same key.
+func readFileMeta(p string) (map[string]string, error) {
+
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret map[string]string
+	for _, m := range metaCommentRE.FindAllSubmatch(b, -1) {
+		meta, err := parseMeta(string(m[1]))
+		if err != nil {
+			return nil, fmt.Errorf("error in %q: %w", p, err)
+		}
+		ret = mergeMeta(ret, meta)
+	}
+
+	return ret, nil
+}
+
+// readMetaDirective returns the route metadata from the meta directives in doc.
+func readMetaDirective(doc *ast.CommentGroup) (map[string]string, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	var ret map[string]string
+	for _, c := range doc.List {
+		if c.Text != metaDirective && !strings.HasPrefix(c.Text, metaDirective+" ") {
+			continue
+		}
+		meta, err := parseMeta(strings.TrimPrefix(c.Text, metaDirective))
+		if err != nil {
+			return nil, err
+		}
+		ret = mergeMeta(ret, meta)
+	}
+	return ret, nil
+}
+
+// parseMeta parses a whitespace separated list of key=value pairs, e.g. `title="User List" role=admin`.
+func parseMeta(s string) (map[string]string, error) {
+
+	ret := make(map[string]string)
+	for strings.TrimSpace(s) != "" {
+		m := metaAttrRE.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf("invalid route metadata %q, expected key=\"value\"", strings.TrimSpace(s))
+		}
+		ret[m[1]] = m[2] + m[3] + m[4] // only one of them is non-empty
+		s = s[len(m[0]):]
+	}
+
+	return ret, nil
+}
+
+// mergeMeta copies the values from src into dst, allocating dst if needed, and returns it.
+func mergeMeta(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -2,32 +2,68 @@ package rgen
 
 import (
 	"bytes"
-	"crypto/md5"
-	"errors"
 	"fmt"
-	"io"
+	"go/format"
+	"go/token"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	"unicode"
 )
 
+// DefaultOutputFile is the name of the file written in each directory if SetOutputFile is not called.
+const DefaultOutputFile = "0_routes_gen.go"
+
+// legacyOutputFile is the name of the file written by earlier versions, it is removed when found.
+const legacyOutputFile = "0_routes_vgen.go"
+
+// banner is the first line of each generated file and is used to detect
+// that a file was generated before overwriting it.
+const banner = "// Code generated by vgrouter/rgen. DO NOT EDIT."
+
+// legacyBanner was used by earlier versions and is also accepted as indicating a generated file.
+const legacyBanner = "// WARNING: This file was generated by vgrouter/rgen. Do not modify."
+
 // New returns a new Generator instance.
 func New() *Generator {
 	return &Generator{}
 }
 
 // Generator performs route generation on a given directory (and optionally sub-directories)
+//
+// Metadata such as a page title or required role can be attached to a route with a comment
+// in the included file:
+//
+//	<!-- vgrouter: title="User List" role=admin -->
+//
+// or when parsing Go source (see SetParseGo) with a doc comment directive on the type:
+//
+//	//vgrouter:meta title="User List" role=admin
+//
+// The metadata is generated in vgRouteMeta, returned by MetaMap and passed to
+// vgrouter.Router.SetRouteMeta by RegisterRoutes, so it is available as RouteMatch.Meta.
 type Generator struct {
 	dir         string                           // starting directory
 	recursive   bool                             // if true we will descend into directories
 	packageName string                           // fully qualified package name corresponding to dir
 	pathFunc    func(fileName string) string     // function derive path from file or struct name
+	dirPathFunc func(dirName string) string      // function to derive path from sub-directory name
 	includeFunc func(path, fileName string) bool // function to determine if a file should be included
+	register    bool                             // if true generate RegisterRoutes function
+	consts      bool                             // if true generate route constants and URL functions
+	outputFile  string                           // name of file to write in each directory
+	force       bool                             // if true overwrite output files even without the banner
+	checkTypes  bool                             // if true verify route types exist in the Go source
+	parseGo     bool                             // if true find components and route directives in the Go source
+	pathMap     map[string]string                // route paths for specific files, keyed by path relative to dir
+	prefix      string                           // prefix for all route paths
+	slash       bool                             // if true route paths end with a slash
+	construct   Construct                        // how components in the route map are constructed
 }
 
 // SetDir assigns the directory to start generating in.
@@ -50,6 +86,93 @@ func (g *Generator) SetPackageName(packageName string) *Generator {
 	return g
 }
 
+// SetRegister if passed true will generate a RegisterRoutes function in each package which
+// adds the routes to a *vgrouter.Router (see vgrouter.Router.AddComponentRoutes).
+func (g *Generator) SetRegister(register bool) *Generator {
+	g.register = register
+	return g
+}
+
+// SetConsts if passed true will generate a constant for each route in the directory set with SetDir
+// (and its sub-directories if recursive), e.g. RoutePage1 = "/page1", plus a function to build the
+// path for each route with parameters, e.g. URLUserId(id string) string for "/user/:id".
+// Like the paths passed to Navigate, the result is not URL escaped (Href does that), so values
+// may contain spaces or "%", but only catch-all parameters may contain "/".
+// Referring to routes this way means removing a page causes a compile error instead of a broken link.
+func (g *Generator) SetConsts(consts bool) *Generator {
+	g.consts = consts
+	return g
+}
+
+// SetOutputFile sets the name of the file written in each directory.
+// If not set, DefaultOutputFile will be used.
+func (g *Generator) SetOutputFile(name string) *Generator {
+	g.outputFile = name
+	return g
+}
+
+// SetForce if passed true will cause output files to be overwritten even if they do not
+// start with the generated file banner.  By default an ErrNotGenerated is returned instead.
+func (g *Generator) SetForce(force bool) *Generator {
+	g.force = force
+	return g
+}
+
+// ErrNotGenerated is returned when an output file exists but does not appear to have been
+// generated by rgen, to avoid overwriting a hand-written file.
+type ErrNotGenerated struct {
+	Path string // path of the existing file
+}
+
+// Error implements error.
+func (e ErrNotGenerated) Error() string {
+	return fmt.Sprintf("refusing to overwrite %q because it was not generated by rgen (use force to override)", e.Path)
+}
+
+// isGenerated returns true if the file contents b starts with the banner, or has the legacy banner
+// in the position earlier versions put it in (after the package clause and a blank line).
+// The rest of the file is not checked, so hand-written files which mention the banner are not matched.
+func isGenerated(b []byte) bool {
+
+	lines := bytes.SplitN(b, []byte("\n"), 4)
+	line := func(i int) string {
+		if i >= len(lines) {
+			return ""
+		}
+		return strings.TrimSpace(string(lines[i]))
+	}
+
+	if line(0) == banner {
+		return true
+	}
+
+	return strings.HasPrefix(line(0), "package ") && line(1) == "" && line(2) == legacyBanner
+}
+
+// SetCheckTypes if passed true will cause Generate to parse the Go files in each directory and
+// return ErrMissingTypes if the type for an included file is not declared, instead of generating
+// code which will not compile.  Note that this requires vugugen to be run before route generation.
+func (g *Generator) SetCheckTypes(checkTypes bool) *Generator {
+	g.checkTypes = checkTypes
+	return g
+}
+
+// SetParseGo if passed true will parse the Go files in each directory and add a route for every
+// exported type which implements vugu.Builder (has a Build(*vugu.BuildIn) *vugu.BuildOut method),
+// in addition to the included files.  Files excluded by build constraints for js/wasm are ignored.
+// The path for a type is derived from its name with DefaultTypePathFunc, e.g. UserList becomes
+// "/user-list", unless the type has a doc comment directive giving the path:
+//
+//	//vgrouter:route /custom/:id
+//	type UserDetail struct { ... }
+//
+// Paths are relative to the directory, as with file names.  The directive also overrides the
+// path for a type generated from an included file, and "//vgrouter:route -" excludes the type.
+func (g *Generator) SetParseGo(parseGo bool) *Generator {
+	g.parseGo = parseGo
+	return g
+}
+
 // SetPathFunc sets a function which transforms.
 // If not set, DefaultPathFunc will be used.
 func (g *Generator) SetPathFunc(f func(fileName string) string) *Generator {
@@ -57,6 +180,64 @@ func (g *Generator) SetPathFunc(f func(fileName string) string) *Generator {
 	return g
 }
 
+// SetPathMap sets the route path to use for specific files, overriding the path function.
+// The keys are file paths relative to the dir set by SetDir using forward slashes,
+// e.g. "user/profile.vugu", and the values are paths relative to the file's directory
+// as returned by the path function, e.g. "/me".
+func (g *Generator) SetPathMap(m map[string]string) *Generator {
+	g.pathMap = m
+	return g
+}
+
+// SetPrefix sets a prefix, e.g. "/app", which is added to all route paths.  It is the default prefix
+// returned by MakeRoutes in the top level package and is included in route constants.
+// Note that this is separate from the Router's path prefix (see vgrouter.Router.SetPathPrefix),
+// which is not part of route paths at all.
+func (g *Generator) SetPrefix(prefix string) *Generator {
+	g.prefix = prefix
+	return g
+}
+
+// SetTrailingSlash if passed true will cause route paths to end with a slash, e.g. "/user/"
+// instead of "/user", in the cleaned route map and route constants (except for "/" and paths
+// ending in a catch-all parameter).  This only changes the URLs generated for routes, the Router
+// matches paths with or without the slash.
+func (g *Generator) SetTrailingSlash(v bool) *Generator {
+	g.slash = v
+	return g
+}
+
+// Construct specifies how the components in the generated route map are constructed.
+type Construct int
+
+const (
+	// ConstructShared creates one instance of each component when the package is initialized,
+	// which is used every time its route is navigated to.  This is the default.
+	ConstructShared Construct = iota
+	// ConstructFresh generates a func() vugu.Builder for each route which returns a new instance,
+	// so state does not carry over between visits to a page.
+	ConstructFresh
+	// ConstructCached generates a func() vugu.Builder for each route which creates the instance the
+	// first time it is called and returns the same one after that, so components are only
+	// allocated for pages which are visited.
+	ConstructCached
+)
+
+// SetConstruct sets how the components in the generated route map are constructed.
+// vgrouter.Router.AddComponentRoutes (and so RegisterRoutes) accepts all of them.
+func (g *Generator) SetConstruct(c Construct) *Generator {
+	g.construct = c
+	return g
+}
+
+// SetDirPathFunc sets a function which transforms a sub-directory name into the path
+// it contributes to the routes underneath it.
+// If not set, DefaultDirPathFunc will be used.
+func (g *Generator) SetDirPathFunc(f func(dirName string) string) *Generator {
+	g.dirPathFunc = f
+	return g
+}
+
 // SetIncludeFunc sets the function which determines which files are included in the route map.
 // The include function will be passed the path relative to the dir set by SetDir (and will be empty
 // for files in that directory) and fileName will contain the base file name.  E.g. given SetDir("/a")
@@ -69,12 +250,42 @@ func (g *Generator) SetIncludeFunc(f func(path, fileName string) bool) *Generato
 
 // DefaultPathFunc will return the fileName with any suffix removed and a slash prepended.
 // E.g. file name "example.vugu" will return "/example".  The special case of index.vugu
-// will return "/".
+// will return "/".  File names which start with an underscore are route parameters, e.g.
+// "_id.vugu" returns "/:id", and two underscores indicate a catch-all parameter which matches
+// the rest of the path, e.g. "__rest.vugu" returns "/*rest".  The forms "[id].vugu" and
+// "[...rest].vugu" are also understood.  However the Generator rejects included files with these
+// names, since the Go tool ignores the code vugugen generates for files whose names begin with an
+// underscore and the bracket form is not a valid type name, so put the page in a parameter
+// directory instead, e.g. "user/_id/index.vugu" for "/user/:id".  Note that the Go tool also skips
+// directories whose names begin with an underscore when expanding patterns like "./...", so
+// "go generate ./..." (for vugugen), "go vet ./..." and "go test ./..." do not reach the pages in
+// parameter directories; name those directories explicitly, e.g. "go generate ./user/_id".
 func DefaultPathFunc(fileName string) string {
 	if fileName == "index.vugu" {
 		return "/"
 	}
-	return "/" + strings.TrimSuffix(fileName, path.Ext(fileName))
+	return "/" + pathSegment(strings.TrimSuffix(fileName, path.Ext(fileName)))
+}
+
+// DefaultDirPathFunc will return the dirName with a slash prepended.  Parameter names are
+// handled the same as DefaultPathFunc, e.g. a directory named "_id" returns "/:id".
+func DefaultDirPathFunc(dirName string) string {
+	return "/" + pathSegment(dirName)
+}
+
+// pathSegment converts a parameter file or directory name into a path parameter.
+func pathSegment(name string) string {
+	switch {
+	case strings.HasPrefix(name, "[...") && strings.HasSuffix(name, "]") && len(name) > 5:
+		return "*" + name[4:len(name)-1]
+	case strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") && len(name) > 2:
+		return ":" + name[1:len(name)-1]
+	case strings.HasPrefix(name, "__") && len(name) > 2:
+		return "*" + name[2:]
+	case strings.HasPrefix(name, "_") && len(name) > 1:
+		return ":" + name[1:]
+	}
+	return name
 }
 
 // DefaultIncludeFunc will return true for any file which ends with .vugu.
@@ -82,19 +293,57 @@ func DefaultIncludeFunc(path, fileName string) bool {
 	return strings.HasSuffix(fileName, ".vugu")
 }
 
-// Generate does the route generation.
+// Affects returns true if adding, removing or (for Go files) changing the named file can change
+// the generated output.  The path and fileName are as passed to the include function (see
+// SetIncludeFunc).  This is used to decide when to regenerate in watch mode.
+func (g *Generator) Affects(path, fileName string) bool {
+
+	includeFunc := g.includeFunc
+	if includeFunc == nil {
+		includeFunc = DefaultIncludeFunc
+	}
+	if includeFunc(path, fileName) {
+		return true
+	}
+
+	return g.parseGo && g.isGoFile(fileName)
+}
+
+// Generate does the route generation, writing the files returned by Render with WriteFiles.
 func (g *Generator) Generate() error {
 
+	files, err := g.Render()
+	if err != nil {
+		return err
+	}
+
+	_, err = g.WriteFiles(files)
+	return err
+}
+
+// File is a file produced by route generation.
+type File struct {
+	Path    string // absolute path of the file
+	Content []byte // generated content, nil if the file should be removed
+}
+
+// Render does the route generation in memory and returns the files which would be written
+// (or removed), without changing anything on disk.
+func (g *Generator) Render() ([]File, error) {
+
 	// to keep our sanity we need to guarantee that g.dir is absolute
 	dir, err := filepath.Abs(g.dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	g.dir = dir
 
 	// auto-detect g.packageName as needed
 	if g.packageName == "" {
 		g.packageName, err = guessImportPath(dir)
+		if err != nil {
+			return nil, err
+		}
 		// cmd := exec.Command("go", "list", "-json")
 		// cmd.Dir = g.dir
 		// b, err := cmd.CombinedOutput()
@@ -113,17 +362,67 @@ func (g *Generator) Generate() error {
 
 	df, err := g.readDirf(g.dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// TODO: prune branches that have nothing to be generated underneath them
+	if g.checkTypes {
+		err = g.verifyTypes(df)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	err = g.writeRoutes(df)
-	if err != nil {
-		return err
+	return g.renderRoutes(df)
+}
+
+// WriteFiles writes the files returned by Render and removes those with nil Content.
+// Files whose content is unchanged are not written.  Before anything is changed each file is
+// checked and ErrNotGenerated is returned if it exists without the generated file banner
+// (unless SetForce(true) was called).  The paths of the files written or removed are returned.
+func (g *Generator) WriteFiles(files []File) ([]string, error) {
+
+	existing := make([][]byte, len(files))
+	for i, f := range files {
+		b, err := ioutil.ReadFile(f.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !g.force && !isGenerated(b) {
+			return nil, ErrNotGenerated{Path: f.Path}
+		}
+		existing[i] = b
+	}
+
+	var changed []string
+	for i, f := range files {
+
+		if f.Content == nil {
+			if existing[i] == nil {
+				continue
+			}
+			err := os.Remove(f.Path)
+			if err != nil {
+				return changed, err
+			}
+			changed = append(changed, f.Path)
+			continue
+		}
+
+		// leave unchanged files alone so timestamps don't churn
+		if bytes.Equal(existing[i], f.Content) {
+			continue
+		}
+		err := writeFileAtomic(f.Path, f.Content)
+		if err != nil {
+			return changed, err
+		}
+		changed = append(changed, f.Path)
 	}
 
-	return nil
+	return changed, nil
 }
 
 func (g *Generator) readDirf(dirPath string) (*dirf, error) {
@@ -143,6 +442,7 @@ func (g *Generator) readDirf(dirPath string) (*dirf, error) {
 	if err != nil {
 		return nil, err
 	}
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
 
 	rel, err := filepath.Rel(g.dir, dirPath)
 	if err != nil {
@@ -164,6 +464,12 @@ func (g *Generator) readDirf(dirPath string) (*dirf, error) {
 			if err != nil {
 				return nil, err
 			}
+			// prune branches that have nothing to be generated underneath them
+			if len(subdirf.routes) == 0 && len(subdirf.subdirs) == 0 {
+				ret.pruned = append(ret.pruned, subdirf.path)
+				ret.pruned = append(ret.pruned, subdirf.pruned...)
+				continue
+			}
 			if ret.subdirs == nil {
 				ret.subdirs = make(map[string]*dirf)
 			}
@@ -172,74 +478,195 @@ func (g *Generator) readDirf(dirPath string) (*dirf, error) {
 		}
 
 		if includeFunc(rel, fi.Name()) {
-			ret.fileNames = append(ret.fileNames, fi.Name())
+			err := checkFileName(rel, fi.Name())
+			if err != nil {
+				return nil, err
+			}
+			p, ok := g.pathMap[path.Join(rel, fi.Name())]
+			if !ok {
+				p = g.filePath(fi.Name())
+			}
+			meta, err := readFileMeta(filepath.Join(dirPath, fi.Name()))
+			if err != nil {
+				return nil, err
+			}
+			ret.routes = append(ret.routes, dirRoute{
+				FileName: fi.Name(),
+				Path:     p,
+				TypeName: structName(fi.Name()),
+				Meta:     meta,
+			})
+		}
+	}
+
+	if g.parseGo {
+		err := g.addGoRoutes(ret, dirPath)
+		if err != nil {
+			return nil, err
 		}
 	}
 
+	sort.SliceStable(ret.routes, func(i, j int) bool { return ret.routes[i].Path < ret.routes[j].Path })
+
 	return ret, nil
 
 }
 
 type dirf struct {
-	path      string           // path relative to g.dir
-	fileNames []string         // list of included files
-	subdirs   map[string]*dirf // children
+	path    string           // path relative to g.dir
+	routes  []dirRoute       // routes for this directory
+	subdirs map[string]*dirf // children
+	pruned  []string         // paths (relative to g.dir) of sub-directories with no routes underneath
+}
+
+// dirRoute is a single route within a directory.
+type dirRoute struct {
+	FileName string            // included file the route came from, empty if found only in Go source
+	Path     string            // route path relative to the directory, e.g. "/page1"
+	TypeName string            // Go type of the component
+	Meta     map[string]string // metadata from the file and Go source, nil if none
 }
 
 func (df *dirf) Path() string { return df.path }
 
-func (g *Generator) writeRoutes(df *dirf) error {
+// MetaLiteral returns the Go composite literal for r.Meta with the keys in sorted order.
+func (r dirRoute) MetaLiteral() string {
+	keys := make([]string, 0, len(r.Meta))
+	for k := range r.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(strconv.Quote(k) + ": " + strconv.Quote(r.Meta[k]))
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// filePath returns the path for a file name using pathFunc or DefaultPathFunc.
+func (g *Generator) filePath(fileName string) string {
+	if g.pathFunc != nil {
+		return g.pathFunc(fileName)
+	}
+	return DefaultPathFunc(fileName)
+}
+
+// dirPath returns the path for a sub-directory name using dirPathFunc or DefaultDirPathFunc.
+func (g *Generator) dirPath(dirName string) string {
+	if g.dirPathFunc != nil {
+		return g.dirPathFunc(dirName)
+	}
+	return DefaultDirPathFunc(dirName)
+}
+
+// renderRoutes returns the output file for df (and its sub-directories if recursive),
+// plus any files to be removed.
+func (g *Generator) renderRoutes(df *dirf) ([]File, error) {
 
-	// TODO: this should be smarter about detecting what local package name is currently in use and using that
-	_, localPackage := path.Split(df.path)
+	localPackage, err := g.goPackageName(filepath.Join(g.dir, df.path))
+	if err != nil {
+		return nil, err
+	}
 	if localPackage == "" {
-		_, localPackage = filepath.Split(g.dir)
+		// no Go files yet, guess from the directory name
+		_, localPackage = path.Split(df.path)
+		if localPackage == "" {
+			_, localPackage = filepath.Split(g.dir)
+		}
+		localPackage = strings.ReplaceAll(localPackage, "-", "_") // dashes not allowed, replace them with underscore for now
+	}
+
+	// route constants go only in the top level package since they need the full paths
+	var consts []constRoute
+	if g.consts && df.path == "" {
+		var err error
+		consts, err = g.constRoutes(df, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// only the top level package gets the prefix, sub-packages are given theirs by the parent
+	prefix := ""
+	if df.path == "" {
+		prefix = g.prefix
+	}
+
+	var subdirs []subdirImport
+	if g.recursive {
+		names := sortedKeys(df.subdirs)
+		aliases := importAliases(names)
+		for _, name := range names {
+			subdirs = append(subdirs, subdirImport{
+				Alias:   aliases[name],
+				Path:    g.packageName + "/" + df.subdirs[name].path,
+				DirPath: g.dirPath(name),
+			})
+		}
 	}
-	localPackage = strings.ReplaceAll(localPackage, "-", "_") // dashes not allowed, replace them with underscore for now
 
 	cm := map[string]interface{}{
 		"LocalPackage": localPackage,
 		"PackageName":  g.packageName,
-		"FileNameList": df.fileNames,
-		"Subdirs":      df.subdirs,
+		"Routes":       df.routes,
+		"Subdirs":      subdirs,
 		"Recursive":    g.recursive,
+		"Banner":       banner,
+		"Register":     g.register,
+		"Consts":       consts,
+		"Prefix":       prefix,
+		"Slash":        g.slash,
+		"Factory":      g.construct != ConstructShared,
+		"Cached":       g.construct == ConstructCached,
 		"G":            g,
 	}
 
-	fm := template.FuncMap{
-		"PathName": func(s string) string {
-			pf := g.pathFunc
-			if pf == nil {
-				pf = DefaultPathFunc
-			}
-			return pf(s)
-		},
-		"StructName": func(s string) string {
-			return structName(s)
-		},
-		"HashIdent": func(s string) string {
-			return fmt.Sprintf("ident%x", md5.Sum([]byte(s)))
-		},
-		"PathBase": path.Base,
+	outputFile := g.outputFile
+	if outputFile == "" {
+		outputFile = DefaultOutputFile
 	}
 
-	t := template.New("0_routes_vgen.go")
-	t.Funcs(fm)
-	t, err := t.Parse(`package {{.LocalPackage}}
+	t := template.New(outputFile)
+	t, err = t.Parse(`{{.Banner}}
 
-// WARNING: This file was generated by vgrouter/rgen. Do not modify.
+package {{.LocalPackage}}
 
 import "path"
-
-{{if .Recursive}}{{range $k, $subdir := .Subdirs}}import {{HashIdent (printf "%s%s" $.PackageName $subdir.Path)}} "{{$.PackageName}}/{{$subdir.Path}}"
-{{end}}{{end}}
+{{if or .Register .Factory}}
+{{if .Register}}import "github.com/vugu/vgrouter"
+{{end}}import "github.com/vugu/vugu"
+{{end}}
+{{range .Subdirs}}import {{.Alias}} "{{.Path}}"
+{{end}}
 
 // routeMap is the generated route mappings for this package.
-// The key is the path and the value is an instance of the component
+// The key is the path and the value is {{if .Factory}}a function which returns the instance{{else}}an instance{{end}} of the component
 // that should be used for it.
 var vgRouteMap = map[string]interface{}{
-{{range $k, $v := .FileNameList}}	"{{PathName $v}}": &{{StructName $v}}{},
+{{range .Routes}}	"{{.Path}}": {{if $.Cached}}vgCached(func() vugu.Builder { return &{{.TypeName}}{} }){{else if $.Factory}}func() vugu.Builder { return &{{.TypeName}}{} }{{else}}&{{.TypeName}}{}{{end}},
+{{end}}
+}
+{{if .Cached}}
+// vgCached returns a function which calls f the first time and then returns the same result.
+func vgCached(f func() vugu.Builder) func() vugu.Builder {
+	var c vugu.Builder
+	return func() vugu.Builder {
+		if c == nil {
+			c = f()
+		}
+		return c
+	}
+}
 {{end}}
+// vgRouteMeta is the generated route metadata for this package, keyed by path.
+var vgRouteMeta = map[string]map[string]string{
+{{range .Routes}}{{if .Meta}}	"{{.Path}}": {{.MetaLiteral}},
+{{end}}{{end}}
 }
 
 type vgroutes struct {
@@ -263,188 +690,263 @@ func (r vgroutes) WithClean(v bool) vgroutes {
 	return r
 }
 
+func (r vgroutes) key(k string) string {
+	if r.clean {
+		k = path.Clean(k){{if .Slash}}
+		if k != "/" && path.Base(k)[0] != '*' {
+			k += "/"
+		}{{end}}
+	}
+	return k
+}
+
 func (r vgroutes) Map() map[string]interface{} {
 	ret := make(map[string]interface{}, len(vgRouteMap))
 	for k, v := range vgRouteMap {
-		key := r.prefix+k
-		if r.clean {
-			key = path.Clean(key)
-		}
-		ret[key] = v
+		ret[r.key(r.prefix+k)] = v
 	}
 
-	{{if .Recursive}}
+	{{- if .Subdirs}}
+
 	if r.recursive {
-		{{range $k, $subdir := .Subdirs}}
-		for k, v := range {{HashIdent (printf "%s%s" $.PackageName $subdir.Path)}}.
+		{{- range .Subdirs}}
+		for k, v := range {{.Alias}}.
 				MakeRoutes().
 				WithClean(r.clean).
 				WithRecursive(true).
-				WithPrefix(r.prefix+"/{{PathBase $subdir.Path}}").
+				WithPrefix(r.prefix+"{{.DirPath}}").
 				Map() {
-			if r.clean {
-				k = path.Clean(k)
-			}
-			ret[k] = v
+			ret[r.key(k)] = v
+		}
+		{{- end}}
+	}
+	{{- end}}
+
+	return ret
+}
+
+// MetaMap returns the route metadata with the same keys as Map, routes without metadata are omitted.
+func (r vgroutes) MetaMap() map[string]map[string]string {
+	ret := make(map[string]map[string]string, len(vgRouteMeta))
+	for k, v := range vgRouteMeta {
+		ret[r.key(r.prefix+k)] = v
+	}
+
+	{{- if .Subdirs}}
+
+	if r.recursive {
+		{{- range .Subdirs}}
+		for k, v := range {{.Alias}}.
+				MakeRoutes().
+				WithClean(r.clean).
+				WithRecursive(true).
+				WithPrefix(r.prefix+"{{.DirPath}}").
+				MetaMap() {
+			ret[r.key(k)] = v
 		}
-		{{end}}
+		{{- end}}
 	}
-	{{end}}
+	{{- end}}
 
 	return ret
 }
 
 // MakeRoutes returns the routes for this package and an sub-packages as applicable.
 func MakeRoutes() vgroutes {
-	return vgroutes{}
+	return vgroutes{ {{- if .Prefix}}prefix: {{printf "%q" .Prefix}}{{end -}} }
+}
+{{if .Register}}
+// RegisterRoutes adds an exact route to r for each component in MakeRoutes{{if .Recursive}} (including sub-packages){{end}}
+// which calls set with the component when it is navigated to, and sets the route metadata.
+// See vgrouter.Router.AddComponentRoutes and vgrouter.Router.SetRouteMeta.
+func RegisterRoutes(r *vgrouter.Router, set func(vugu.Builder)) error {
+	rs := MakeRoutes().WithRecursive({{.Recursive}}).WithClean(true)
+	for p, meta := range rs.MetaMap() {
+		err := r.SetRouteMeta(p, meta)
+		if err != nil {
+			return err
+		}
+	}
+	return r.AddComponentRoutes(rs.Map(), set)
 }
-`)
+{{end}}{{if .Consts}}
+// Route paths, with parameters as :param.
+const (
+{{range .Consts}}	Route{{.Ident}} = {{printf "%q" .Path}}
+{{end}})
+{{range .Consts}}{{if .Args}}
+// URL{{.Ident}} returns the path for Route{{.Ident}} with the parameters filled in, for use with Navigate.
+func URL{{.Ident}}({{.Args}}) string {
+	return {{.Expr}}
+}
+{{end}}{{end}}{{end}}`)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var buf bytes.Buffer
 	err = t.Execute(&buf, cm)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fullRouteMapPath := filepath.Join(g.dir, df.path, "0_routes_vgen.go")
+	fullRouteMapPath := filepath.Join(g.dir, df.path, outputFile)
 
-	err = ioutil.WriteFile(fullRouteMapPath, buf.Bytes(), 0644)
+	src, err := format.Source(buf.Bytes())
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("error formatting generated code for %q: %w", fullRouteMapPath, err)
 	}
 
-	b, err := exec.Command("go", "fmt", fullRouteMapPath).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error running go fmt on %q: %w; full output: %s", fullRouteMapPath, err, b)
+	ret := []File{{Path: fullRouteMapPath, Content: src}}
+
+	// remove our output from pruned directories, it may refer to types which no longer exist
+	for _, p := range df.pruned {
+		ret = g.appendRemove(ret, filepath.Join(g.dir, p, outputFile))
+	}
+
+	// remove file left by earlier versions, it would have duplicate declarations
+	if outputFile != legacyOutputFile {
+		ret = g.appendRemove(ret, filepath.Join(g.dir, df.path, legacyOutputFile))
 	}
 
 	if g.recursive {
 		// recurse into subdirs
-		for _, subdf := range df.subdirs {
-			err := g.writeRoutes(subdf)
+		for _, name := range sortedKeys(df.subdirs) {
+			subdf := df.subdirs[name]
+			files, err := g.renderRoutes(subdf)
 			if err != nil {
-				return fmt.Errorf("error in writeRoutes for %q: %w", subdf.path, err)
+				return nil, fmt.Errorf("error in renderRoutes for %q: %w", subdf.path, err)
 			}
+			ret = append(ret, files...)
 		}
 	}
 
-	return nil
+	return ret, nil
 }
 
-func structName(s string) string {
-
-	// // trim file extension
-	// s = strings.TrimSuffix(s, path.Ext(s))
+// subdirImport is a sub-directory package imported by the generated code.
+type subdirImport struct {
+	Alias   string // import alias, see importAliases
+	Path    string // import path
+	DirPath string // path the directory contributes to its routes, e.g. "/section1"
+}
 
-	// // if any upper case letters we use the file name as-is
-	// for _, c := range s {
-	// 	if unicode.IsUpper(c) {
-	// 		return s
-	// 	}
-	// }
+// importAliases returns a readable import alias for each sub-directory name, e.g. "section1Routes" for
+// "section1" and "idRoutes" for "_id".  Names which would result in the same alias are numbered
+// in the order given, e.g. "page-a" and "page_a" become "pageARoutes" and "pageARoutes2".
+// The "Routes" suffix avoids conflicts with the names of the other imports and declarations.
+func importAliases(names []string) map[string]string {
 
-	// otherwise we transform it the same way vugu does
-	return fnameToGoTypeName(s)
+	ret := make(map[string]string, len(names))
+	used := make(map[string]bool, len(names))
+	for _, name := range names {
 
-}
+		base := identPart(name)
+		if base == "" || !unicode.IsLetter([]rune(base)[0]) {
+			base = "Dir" + base
+		}
+		r := []rune(base)
+		base = string(unicode.ToLower(r[0])) + string(r[1:]) + "Routes"
 
-func fnameToGoTypeName(s string) string {
-	s = strings.Split(s, ".")[0] // remove file extension if present
-	parts := strings.Split(s, "-")
-	for i := range parts {
-		p := parts[i]
-		if len(p) > 0 {
-			p = strings.ToUpper(p[:1]) + p[1:]
+		alias := base
+		for i := 2; used[alias]; i++ {
+			alias = base + strconv.Itoa(i)
 		}
-		parts[i] = p
+		used[alias] = true
+		ret[name] = alias
 	}
-	return strings.Join(parts, "")
-}
-
-func guessImportPath(dir string) (string, error) {
 
-	after := ""
-	lastDir := dir
+	return ret
+}
 
-	for {
-		f, err := os.Open(filepath.Join(dir, "go.mod"))
-		if err == nil {
-			defer f.Close()
-			ret, err := readModuleEntry(f)
-			return ret + after, err
-		}
+// appendRemove appends a File to remove p if it exists and was generated by us.
+func (g *Generator) appendRemove(files []File, p string) []File {
+	b, err := ioutil.ReadFile(p)
+	if err == nil && isGenerated(b) {
+		files = append(files, File{Path: p})
+	}
+	return files
+}
 
-		after = "/" + filepath.Base(dir) + after
+// writeFileAtomic writes b to a temporary file in the same directory as p and then renames it to p,
+// so p is never left partially written.
+func writeFileAtomic(p string, b []byte) (reterr error) {
 
-		dir, err = filepath.Abs(filepath.Join(dir, ".."))
-		if err != nil {
-			return "", err
+	// the dot prefix means the Go tool will ignore it if we are interrupted
+	f, err := ioutil.TempFile(filepath.Dir(p), "."+filepath.Base(p)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if reterr != nil {
+			f.Close()
+			os.Remove(f.Name())
 		}
+	}()
 
-		if dir == lastDir { // we hit the root dir
-			return "", fmt.Errorf("no go.mod file found, cannot guess import path")
-		}
+	_, err = f.Write(b)
+	if err != nil {
+		return err
+	}
+	err = f.Chmod(0644)
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
 	}
 
+	return os.Rename(f.Name(), p)
 }
 
-func readModuleEntry(r io.Reader) (string, error) {
+// checkFileName returns an error if the component vugugen generates for the included file name
+// in the directory rel cannot be used, see DefaultPathFunc.
+func checkFileName(rel, name string) error {
 
-	b, err := ioutil.ReadAll(r)
-	if err != nil {
-		return "", err
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	tn := structName(name)
+	if !strings.HasPrefix(name, "_") && token.IsIdentifier(tn) {
+		return nil
 	}
 
-	ret := modulePath(b)
-	if ret == "" {
-		return "", errors.New("unable to determine module path from go.mod")
+	msg := fmt.Sprintf("%q is not a valid Go type name", tn)
+	if strings.HasPrefix(name, "_") {
+		msg = "the Go tool ignores the code generated for file names starting with an underscore"
 	}
 
-	return ret, nil
+	suggest := ""
+	if seg := pathSegment(stem); seg != stem {
+		dir := "_" + seg[1:]
+		if seg[0] == '*' {
+			dir = "__" + seg[1:]
+		}
+		suggest = fmt.Sprintf(", use a parameter directory instead, e.g. %q (the Go tool skips it for ./... patterns, so run go generate on it explicitly)",
+			path.Join(rel, dir, "index.vugu"))
+	}
+
+	return fmt.Errorf("unable to generate route for file %q: %s%s", path.Join(rel, name), msg, suggest)
 }
 
-// shamelessly stolen from: https://github.com/golang/vgo/blob/master/vendor/cmd/go/internal/modfile/read.go#L837
-// ModulePath returns the module path from the gomod file text.
-// If it cannot find a module path, it returns an empty string.
-// It is tolerant of unrelated problems in the go.mod file.
-func modulePath(mod []byte) string {
-	for len(mod) > 0 {
-		line := mod
-		mod = nil
-		if i := bytes.IndexByte(line, '\n'); i >= 0 {
-			line, mod = line[:i], line[i+1:]
-		}
-		if i := bytes.Index(line, slashSlash); i >= 0 {
-			line = line[:i]
-		}
-		line = bytes.TrimSpace(line)
-		if !bytes.HasPrefix(line, moduleStr) {
-			continue
-		}
-		line = line[len(moduleStr):]
-		n := len(line)
-		line = bytes.TrimSpace(line)
-		if len(line) == n || len(line) == 0 {
-			continue
-		}
+// structName returns the name of the Go type vugugen generates for the file name s.
+func structName(s string) string {
+	return fnameToGoTypeName(s)
+}
 
-		if line[0] == '"' || line[0] == '`' {
-			p, err := strconv.Unquote(string(line))
-			if err != nil {
-				return "" // malformed quoted string or multiline module path
-			}
-			return p
+// fnameToGoTypeName must produce exactly the same result as the function of the
+// same name in github.com/vugu/vugu/gen so the type names we emit are the ones vugugen
+// declares.  Everything after the first dot is removed, the name is split on dashes and
+// each part has its first letter upper cased.  The rest is left as-is, so MixedCase.vugu
+// gives MixedCase, page-one.vugu gives PageOne and my_page.vugu gives My_page.
+func fnameToGoTypeName(s string) string {
+	s = strings.Split(s, ".")[0] // remove file extension if present
+	parts := strings.Split(s, "-")
+	for i := range parts {
+		p := parts[i]
+		if len(p) > 0 {
+			p = strings.ToUpper(p[:1]) + p[1:]
 		}
-
-		return string(line)
+		parts[i] = p
 	}
-	return "" // missing module path
+	return strings.Join(parts, "")
 }
-
-var (
-	slashSlash = []byte("//")
-	moduleStr  = []byte("module")
-)
@@ -1,18 +1,30 @@
 package rgen
 
 import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/vugu/vugu/gen"
 )
 
+var updateGolden = flag.Bool("update", false, "Update the golden files in testdata instead of comparing with them")
+
 func TestFull(t *testing.T) {
 
 	tmpDir, err := ioutil.TempDir("", "rgen")
@@ -128,6 +140,840 @@ func TestOutput(t *testing.T) {
 
 }
 
+func TestNoClobber(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	outPath := filepath.Join(tmpDir, DefaultOutputFile)
+	legacyPath := filepath.Join(tmpDir, legacyOutputFile)
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "index.vugu"), []byte("<div></div>"), 0644))
+	must(ioutil.WriteFile(outPath, []byte("package x\n\n// hand-written\n"), 0644))
+	must(ioutil.WriteFile(legacyPath, []byte("package x\n\n"+legacyBanner+"\n"), 0644))
+
+	err = New().SetDir(tmpDir).SetPackageName("example.com/x").Generate()
+	if _, ok := err.(ErrNotGenerated); !ok {
+		t.Fatalf("expected ErrNotGenerated, got %v", err)
+	}
+
+	err = New().SetDir(tmpDir).SetPackageName("example.com/x").SetForce(true).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), banner+"\n") {
+		t.Errorf("banner missing from output:\n%s", b)
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Errorf("expected legacy file to be removed, got: %v", err)
+	}
+
+	// now that it has the banner it can be overwritten
+	err = New().SetDir(tmpDir).SetPackageName("example.com/x").Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a file which only quotes the banner is not generated
+	must(ioutil.WriteFile(outPath, []byte("package x\n\n// files start with: "+banner+"\nconst b = \""+legacyBanner+"\"\n"), 0644))
+	err = New().SetDir(tmpDir).SetPackageName("example.com/x").Generate()
+	if _, ok := err.(ErrNotGenerated); !ok {
+		t.Fatalf("expected ErrNotGenerated for file quoting the banner, got %v", err)
+	}
+
+}
+
+func TestIsGenerated(t *testing.T) {
+
+	var tlist = []struct {
+		in string
+		ok bool
+	}{
+		{banner + "\n\npackage x\n", true},
+		{banner + "\r\n\r\npackage x\r\n", true},
+		{"package x\n\n" + legacyBanner + "\n", true},
+		{"package x\n\n// " + banner + "\n", false},
+		{"// Package x does things.\n// " + banner + "\npackage x\n", false},
+		{"package x\n\nconst s = `\n" + banner + "\n`\n", false},
+		{"package x\n\nfunc f() {}\n\n" + legacyBanner + "\n", false},
+		{"", false},
+	}
+
+	for _, ti := range tlist {
+		if ok := isGenerated([]byte(ti.in)); ok != ti.ok {
+			t.Errorf("isGenerated(%q): expected %v, got %v", ti.in, ti.ok, ok)
+		}
+	}
+
+}
+
+func TestStructName(t *testing.T) {
+
+	var tlist = []struct {
+		in, out string
+	}{
+		{"index.vugu", "Index"},
+		{"page1.vugu", "Page1"},
+		{"page-a.vugu", "PageA"},
+		{"MixedCase.vugu", "MixedCase"},
+		{"mixedCase.vugu", "MixedCase"},
+		{"my_page.vugu", "My_page"},
+		{"page-2-b.vugu", "Page2B"},
+		{"page.one.vugu", "Page"},
+	}
+
+	for _, ti := range tlist {
+		if out := structName(ti.in); out != ti.out {
+			t.Errorf("structName(%q): expected %q, got %q", ti.in, ti.out, out)
+		}
+	}
+
+}
+
+func TestCheckFileName(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	must(os.MkdirAll(filepath.Join(tmpDir, "user", "_id"), 0755))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "user", "_id", "index.vugu"), []byte("<div></div>"), 0644))
+
+	for name, expected := range map[string]string{
+		"_id.vugu":       `unable to generate route for file "user/_id.vugu": the Go tool ignores the code generated for file names starting with an underscore, use a parameter directory instead, e.g. "user/_id/index.vugu" (the Go tool skips it for ./... patterns, so run go generate on it explicitly)`,
+		"__rest.vugu":    `unable to generate route for file "user/__rest.vugu": the Go tool ignores the code generated for file names starting with an underscore, use a parameter directory instead, e.g. "user/__rest/index.vugu" (the Go tool skips it for ./... patterns, so run go generate on it explicitly)`,
+		"[id].vugu":      `unable to generate route for file "user/[id].vugu": "[id]" is not a valid Go type name, use a parameter directory instead, e.g. "user/_id/index.vugu" (the Go tool skips it for ./... patterns, so run go generate on it explicitly)`,
+		"[...rest].vugu": `unable to generate route for file "user/[...rest].vugu": "[" is not a valid Go type name, use a parameter directory instead, e.g. "user/__rest/index.vugu" (the Go tool skips it for ./... patterns, so run go generate on it explicitly)`,
+		"2fa.vugu":       `unable to generate route for file "user/2fa.vugu": "2fa" is not a valid Go type name`,
+	} {
+		p := filepath.Join(tmpDir, "user", name)
+		must(ioutil.WriteFile(p, []byte("<div></div>"), 0644))
+		_, err := New().SetDir(tmpDir).SetPackageName("example.com/x").SetRecursive(true).Render()
+		if err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error %q, got %v", name, expected, err)
+		}
+		must(os.Remove(p))
+	}
+
+	_, err = New().SetDir(tmpDir).SetPackageName("example.com/x").SetRecursive(true).Render()
+	if err != nil {
+		t.Errorf("unexpected error for parameter directory: %v", err)
+	}
+
+}
+
+func TestCheckTypes(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "index.vugu"), []byte("<div></div>"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "MyPage.vugu"), []byte("<div></div>"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "index_vgen.go"), []byte("package x\n\ntype Index struct{}\n"), 0644))
+
+	err = New().SetDir(tmpDir).SetPackageName("example.com/x").SetCheckTypes(true).Generate()
+	mt, ok := err.(ErrMissingTypes)
+	if !ok {
+		t.Fatalf("expected ErrMissingTypes, got %v", err)
+	}
+	if len(mt.Types) != 1 || !strings.HasPrefix(mt.Types[0], "MyPage ") {
+		t.Errorf("unexpected missing types: %#v", mt.Types)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, DefaultOutputFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no output file, got: %v", err)
+	}
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "MyPage_vgen.go"), []byte("package x\n\ntype MyPage struct{}\n"), 0644))
+	err = New().SetDir(tmpDir).SetPackageName("example.com/x").SetCheckTypes(true).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestParseGo(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "index.vugu"), []byte("<div></div>"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "page-a.vugu"), []byte("<div></div>"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "nav-bar.vugu"), []byte("<div></div>"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "comps.go"), []byte(`package x
+
+import "github.com/vugu/vugu"
+
+type Index struct{}
+
+func (c *Index) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return nil }
+
+// PageA shows a thing.
+//vgrouter:route /a/:id
+type PageA struct{}
+
+//vgrouter:route -
+type NavBar struct{}
+
+type UserSettings struct{}
+
+func (c *UserSettings) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return nil }
+
+type (
+	//vgrouter:route /custom
+	Custom struct{}
+
+	helper struct{}
+
+	NotComponent struct{}
+)
+
+func (c Custom) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return nil }
+
+func (c *helper) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return nil }
+
+type NotBuilder struct{}
+
+func (c *NotBuilder) Build(s string) error { return nil }
+`), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "aliased.go"), []byte(`package x
+
+import vg "github.com/vugu/vugu"
+
+type Aliased struct{}
+
+func (c *Aliased) Build(vgin *vg.BuildIn) *vg.BuildOut { return nil }
+
+type WrongPkg struct{}
+
+func (c *WrongPkg) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return nil }
+`), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "gen.go"), []byte(`//go:build ignore
+// +build ignore
+
+package main
+
+type Ignored struct{}
+
+func (c *Ignored) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return nil }
+`), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "server.go"), []byte(`//go:build !wasm
+// +build !wasm
+
+package x
+
+type ServerOnly struct{}
+
+func (c *ServerOnly) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return nil }
+`), 0644))
+
+	g := New().SetDir(tmpDir).SetParseGo(true)
+	df, err := g.readDirf(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := make(map[string]string)
+	for _, r := range df.routes {
+		routes[r.Path] = r.TypeName
+	}
+	expected := map[string]string{
+		"/":              "Index",
+		"/a/:id":         "PageA",
+		"/user-settings": "UserSettings",
+		"/custom":        "Custom",
+		"/aliased":       "Aliased",
+	}
+	if !reflect.DeepEqual(routes, expected) {
+		t.Errorf("expected %#v, got %#v", expected, routes)
+	}
+
+	name, err := g.goPackageName(tmpDir)
+	if err != nil || name != "x" {
+		t.Errorf("expected package x, got %q, %v", name, err)
+	}
+
+}
+
+func TestDefaultTypePathFunc(t *testing.T) {
+
+	var tlist = []struct {
+		in, out string
+	}{
+		{"Index", "/"},
+		{"Page1", "/page1"},
+		{"UserList", "/user-list"},
+		{"HTMLPage", "/html-page"},
+		{"PageHTML", "/page-html"},
+	}
+
+	for _, ti := range tlist {
+		if out := DefaultTypePathFunc(ti.in); out != ti.out {
+			t.Errorf("DefaultTypePathFunc(%q): expected %q, got %q", ti.in, ti.out, out)
+		}
+	}
+
+}
+
+func TestPruneAndPackageName(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "index.vugu"), []byte("<div></div>"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package myapp\n"), 0644))
+	must(os.MkdirAll(filepath.Join(tmpDir, "assets", "img"), 0755))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "assets", "img", "logo.png"), []byte("x"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "assets", "img", DefaultOutputFile), []byte(banner+"\n\npackage img\n"), 0644))
+	must(os.MkdirAll(filepath.Join(tmpDir, "pages"), 0755))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "pages", "a.vugu"), []byte("<div></div>"), 0644))
+
+	err = New().SetDir(tmpDir).SetPackageName("example.com/x").SetRecursive(true).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(tmpDir, DefaultOutputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "package myapp\n") {
+		t.Errorf("expected package name from main.go, got:\n%s", b)
+	}
+	if strings.Contains(string(b), "assets") || !strings.Contains(string(b), `"example.com/x/pages"`) {
+		t.Errorf("unexpected imports:\n%s", b)
+	}
+
+	for _, p := range []string{"assets", "assets/img"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, p, DefaultOutputFile)); !os.IsNotExist(err) {
+			t.Errorf("expected no output in %s, got: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "pages", DefaultOutputFile)); err != nil {
+		t.Error(err)
+	}
+
+}
+
+func TestUnchangedNotWritten(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "index.vugu"), []byte("<div></div>"), 0644))
+	outPath := filepath.Join(tmpDir, DefaultOutputFile)
+
+	must(New().SetDir(tmpDir).SetPackageName("example.com/x").Generate())
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	must(os.Chtimes(outPath, old, old))
+
+	must(New().SetDir(tmpDir).SetPackageName("example.com/x").Generate())
+	fi, err := os.Stat(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(old) {
+		t.Errorf("unchanged output file was rewritten")
+	}
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "page1.vugu"), []byte("<div></div>"), 0644))
+	must(New().SetDir(tmpDir).SetPackageName("example.com/x").Generate())
+	b, err := ioutil.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"/page1": &Page1{}`) {
+		t.Errorf("expected new route in output:\n%s", b)
+	}
+
+	// no temporary files left behind
+	fis, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".") {
+			t.Errorf("unexpected file %q", fi.Name())
+		}
+	}
+
+}
+
+func TestCheck(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "index.vugu"), []byte("<div></div>"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "page1.vugu"), []byte("<div></div>"), 0644))
+	outPath := filepath.Join(tmpDir, DefaultOutputFile)
+
+	diffs, err := New().SetDir(tmpDir).SetPackageName("example.com/x").Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || !diffs[0].Missing || diffs[0].Path != outPath ||
+		!reflect.DeepEqual(diffs[0].AddedRoutes, []string{"/", "/page1"}) {
+		t.Errorf("unexpected diffs: %#v", diffs)
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Errorf("Check should not write files, got: %v", err)
+	}
+
+	must(New().SetDir(tmpDir).SetPackageName("example.com/x").Generate())
+	diffs, err = New().SetDir(tmpDir).SetPackageName("example.com/x").Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("unexpected diffs: %#v", diffs)
+	}
+
+	must(os.Remove(filepath.Join(tmpDir, "page1.vugu")))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "page2.vugu"), []byte("<div></div>"), 0644))
+	diffs, err = New().SetDir(tmpDir).SetPackageName("example.com/x").Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Missing ||
+		!reflect.DeepEqual(diffs[0].AddedRoutes, []string{"/page2"}) ||
+		!reflect.DeepEqual(diffs[0].RemovedRoutes, []string{"/page1"}) {
+		t.Errorf("unexpected diffs: %#v", diffs)
+	}
+
+}
+
+func TestDefaultPathFunc(t *testing.T) {
+
+	var tlist = []struct {
+		in, out string
+	}{
+		{"index.vugu", "/"},
+		{"page1.vugu", "/page1"},
+		{"page-a.vugu", "/page-a"},
+		{"_id.vugu", "/:id"},
+		{"__rest.vugu", "/*rest"},
+		{"[id].vugu", "/:id"},
+		{"[...rest].vugu", "/*rest"},
+		{"_.vugu", "/_"},
+		{"[].vugu", "/[]"},
+	}
+
+	for _, ti := range tlist {
+		if out := DefaultPathFunc(ti.in); out != ti.out {
+			t.Errorf("DefaultPathFunc(%q): expected %q, got %q", ti.in, ti.out, out)
+		}
+	}
+
+	if out := DefaultDirPathFunc("_id"); out != "/:id" {
+		t.Errorf("DefaultDirPathFunc: unexpected result %q", out)
+	}
+	if out := DefaultDirPathFunc("section1"); out != "/section1" {
+		t.Errorf("DefaultDirPathFunc: unexpected result %q", out)
+	}
+
+}
+
+func TestMakeConstRoute(t *testing.T) {
+
+	var tlist = []struct {
+		in    string
+		ident string
+		args  string
+		expr  string
+	}{
+		{"/", "Index", "", `"/"`},
+		{"/page-a", "PageA", "", `"/page-a"`},
+		{"/section1/page-b", "Section1PageB", "", `"/section1/page-b"`},
+		{"/user/:id", "UserId", "id string", `"/user/" + id`},
+		{"/user/:id/edit", "UserIdEdit", "id string", `"/user/" + id + "/edit"`},
+		{"/:a/:b", "AB", "a, b string", `"/" + a + "/" + b`},
+		{"/files/*rest", "FilesRest", "rest string", `"/files/" + rest`},
+		{"/x/:type", "XType", "param0 string", `"/x/" + param0`},
+		{"/user/:id/", "UserId", "id string", `"/user/" + id + "/"`},
+		{"/page-a/", "PageA", "", `"/page-a/"`},
+		{"/über/ßtraße", "Überßtraße", "", `"/über/ßtraße"`},
+		{"/:a/x/:a", "AXA", "a, a2 string", `"/" + a + "/x/" + a2`},
+		{"/:a/:a2/:a", "AA2A", "a, a2, a3 string", `"/" + a + "/" + a2 + "/" + a3`},
+	}
+
+	for _, ti := range tlist {
+		cr := makeConstRoute(ti.in)
+		if cr.Ident != ti.ident || cr.Args != ti.args || cr.Expr != ti.expr {
+			t.Errorf("makeConstRoute(%q): unexpected result %#v", ti.in, cr)
+		}
+		// the generated function must compile
+		src := "package x\nconst Route" + cr.Ident + " = " + strconv.Quote(cr.Path) + "\n"
+		if cr.Args != "" {
+			src += "func URL" + cr.Ident + "(" + cr.Args + ") string { return " + cr.Expr + " }\n"
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "", src, 0)
+		if err == nil {
+			_, err = (&types.Config{}).Check("x", fset, []*ast.File{f}, nil)
+		}
+		if err != nil || !utf8.ValidString(src) {
+			t.Errorf("makeConstRoute(%q): invalid Go %q: %v", ti.in, src, err)
+		}
+	}
+
+}
+
+func TestAffects(t *testing.T) {
+
+	g := New()
+	if !g.Affects("", "page1.vugu") {
+		t.Errorf("expected .vugu file to affect output")
+	}
+	if g.Affects("", "page1.go") {
+		t.Errorf("expected .go file not to affect output without parse mode")
+	}
+
+	g.SetParseGo(true)
+	if !g.Affects("a", "page1.go") {
+		t.Errorf("expected .go file to affect output in parse mode")
+	}
+	for _, name := range []string{"page1_test.go", DefaultOutputFile, ".page1.go", "page1.txt"} {
+		if g.Affects("", name) {
+			t.Errorf("expected %q not to affect output", name)
+		}
+	}
+
+}
+
+func TestConfig(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	must(os.MkdirAll(filepath.Join(tmpDir, "admin"), 0755))
+	must(os.MkdirAll(filepath.Join(tmpDir, "user"), 0755))
+	for _, name := range []string{"index.vugu", "about-us.vugu", "draft.vugu", "notes.txt", "admin/index.vugu", "user/profile.vugu"} {
+		must(ioutil.WriteFile(filepath.Join(tmpDir, name), []byte("<div></div>"), 0644))
+	}
+	must(ioutil.WriteFile(filepath.Join(tmpDir, ConfigFile), []byte(`{
+	"exclude": ["draft.vugu", "admin/**"],
+	"paths": {"about-us.vugu": "/about", "user/profile.vugu": "/me"},
+	"prefix": "/app",
+	"output": "routes_gen.go",
+	"trailingSlash": true
+}`), 0644))
+
+	c, err := ReadConfig(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := c.Apply(New().SetDir(tmpDir).SetPackageName("example.com/app").SetRecursive(true).SetConsts(true))
+	files, err := g.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 || files[0].Path != filepath.Join(tmpDir, "routes_gen.go") {
+		t.Fatalf("unexpected files %#v", files)
+	}
+	t.Logf("OUTPUT:\n%s", files[0].Content)
+	src := strings.Join(strings.Fields(string(files[0].Content)), " ")
+	for _, s := range []string{`"/about": &AboutUs{}`, `prefix: "/app"`, `RouteAbout = "/app/about/"`, `RouteUserMe = "/app/user/me/"`, `k += "/"`} {
+		if !strings.Contains(src, s) {
+			t.Errorf("output does not contain %q", s)
+		}
+	}
+	for _, s := range []string{"Draft", "admin"} {
+		if strings.Contains(src, s) {
+			t.Errorf("output contains excluded %q", s)
+		}
+	}
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, ConfigFile), []byte(`{"exlcude": ["*.vugu"]}`), 0644))
+	_, err = ReadConfig(tmpDir)
+	if err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+
+	c, err = ReadConfig(filepath.Join(tmpDir, "user"))
+	if c != nil || err != nil {
+		t.Errorf("expected nil config and error for missing file, got %v, %v", c, err)
+	}
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "vgrouter.yaml"), []byte("prefix: /app\n"), 0644))
+	_, err = ReadConfigFile(filepath.Join(tmpDir, "vgrouter.yaml"), false)
+	if err == nil || !strings.Contains(err.Error(), "only JSON") {
+		t.Errorf("expected error for YAML config, got %v", err)
+	}
+
+}
+
+func TestMatchGlob(t *testing.T) {
+
+	var tlist = []struct {
+		pattern, p string
+		ok         bool
+	}{
+		{"*.vugu", "index.vugu", true},
+		{"*.vugu", "a/b/index.vugu", true},
+		{"*.vugu", "index.html", false},
+		{"admin/*.vugu", "admin/index.vugu", true},
+		{"admin/*.vugu", "admin/x/index.vugu", false},
+		{"admin/**", "admin/x/index.vugu", true},
+		{"**/index.vugu", "index.vugu", true},
+		{"**/index.vugu", "a/b/index.vugu", true},
+		{"a/**/c.vugu", "a/c.vugu", true},
+		{"a/**/c.vugu", "a/b/x/c.vugu", true},
+		{"a/**/c.vugu", "b/c.vugu", false},
+	}
+
+	for _, ti := range tlist {
+		if ok := matchGlob(ti.pattern, ti.p); ok != ti.ok {
+			t.Errorf("matchGlob(%q, %q): expected %v, got %v", ti.pattern, ti.p, ti.ok, ok)
+		}
+	}
+
+}
+
+func TestRouteMeta(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "index.vugu"), []byte(`<!-- vgrouter: title="Home Page" -->
+<div></div>`), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "users.vugu"), []byte(`<!--
+	vgrouter: title='Users' role=admin layout="main"
+-->
+<div><!-- other comment --></div>`), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "comps.go"), []byte(`package x
+
+import "github.com/vugu/vugu"
+
+//vgrouter:meta role=user
+type Users struct{}
+
+func (c *Users) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return nil }
+
+//vgrouter:meta title="Settings"
+type Settings struct{}
+
+func (c *Settings) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return nil }
+`), 0644))
+
+	g := New().SetDir(tmpDir).SetPackageName("example.com/x").SetParseGo(true)
+	df, err := g.readDirf(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metas := make(map[string]map[string]string)
+	for _, r := range df.routes {
+		metas[r.Path] = r.Meta
+	}
+	expected := map[string]map[string]string{
+		"/":         {"title": "Home Page"},
+		"/users":    {"title": "Users", "role": "user", "layout": "main"},
+		"/settings": {"title": "Settings"},
+	}
+	if !reflect.DeepEqual(metas, expected) {
+		t.Errorf("expected %#v, got %#v", expected, metas)
+	}
+
+	files, err := g.renderRoutes(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(strings.Fields(string(files[0].Content)), " "), `"/": {"title": "Home Page"},`) {
+		t.Errorf("vgRouteMeta not found in output:\n%s", files[0].Content)
+	}
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "index.vugu"), []byte(`<!-- vgrouter: title="unterminated -->`), 0644))
+	_, err = g.readDirf(tmpDir)
+	if err == nil {
+		t.Errorf("expected error for invalid metadata")
+	}
+
+}
+
+func TestGolden(t *testing.T) {
+
+	inDir, err := filepath.Abs(filepath.Join("testdata", "golden", "input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tlist = []struct {
+		name string
+		g    *Generator
+	}{
+		{"default", New()},
+		{"full", New().SetRecursive(true).SetRegister(true).SetConsts(true)},
+		{"fresh", New().SetConstruct(ConstructFresh)},
+		{"cached", New().SetConstruct(ConstructCached)},
+	}
+
+	for _, ti := range tlist {
+		t.Run(ti.name, func(t *testing.T) {
+
+			files, err := ti.g.SetDir(inDir).SetPackageName("example.com/app").Render()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// all of the output in one file, each preceded by a line with its path
+			var sb strings.Builder
+			for _, f := range files {
+				rel, err := filepath.Rel(inDir, f.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if f.Content == nil {
+					sb.WriteString("-- " + filepath.ToSlash(rel) + " (removed) --\n")
+					continue
+				}
+				sb.WriteString("-- " + filepath.ToSlash(rel) + " --\n")
+				sb.Write(f.Content)
+			}
+
+			goldenPath := filepath.Join("testdata", "golden", ti.name+".golden")
+			if *updateGolden {
+				must(ioutil.WriteFile(goldenPath, []byte(sb.String()), 0644))
+				return
+			}
+
+			b, err := ioutil.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != sb.String() {
+				t.Errorf("output does not match %s (run with -update if the change is expected), got:\n%s", goldenPath, sb.String())
+			}
+		})
+	}
+
+}
+
+func TestImportAliases(t *testing.T) {
+
+	aliases := importAliases([]string{"section1", "_id", "__rest", "page-a", "page_a", "2020", "-"})
+	expected := map[string]string{
+		"section1": "section1Routes",
+		"_id":      "idRoutes",
+		"__rest":   "restRoutes",
+		"page-a":   "pageARoutes",
+		"page_a":   "pageARoutes2",
+		"2020":     "dir2020Routes",
+		"-":        "dirRoutes",
+	}
+	if !reflect.DeepEqual(aliases, expected) {
+		t.Errorf("expected %#v, got %#v", expected, aliases)
+	}
+
+}
+
+func TestGuessImportPath(t *testing.T) {
+
+	tmpDir, err := ioutil.TempDir("", "rgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// make sure the environment doesn't affect which go.work is used
+	gowork, ok := os.LookupEnv("GOWORK")
+	must(os.Unsetenv("GOWORK"))
+	if ok {
+		defer os.Setenv("GOWORK", gowork)
+	}
+
+	for _, d := range []string{"root/a/b", "root/nested/x", "root/vendor/github.com/x/y", "root/nomodule", "other"} {
+		must(os.MkdirAll(filepath.Join(tmpDir, d), 0755))
+	}
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "root/go.mod"), []byte("module example.com/root // comment\n\ngo 1.14\n"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "root/nested/go.mod"), []byte("module \"example.com/nested\"\n"), 0644))
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "root/nomodule/go.mod"), []byte("go 1.14\n"), 0644))
+
+	var tlist = []struct {
+		dir string
+		out string // empty if an error is expected
+	}{
+		{"root", "example.com/root"},
+		{"root/a/b", "example.com/root/a/b"},
+		{"root/nested", "example.com/nested"},
+		{"root/nested/x", "example.com/nested/x"},
+		{"root/vendor/github.com/x/y", "github.com/x/y"},
+		{"root/vendor", ""},
+		{"root/nomodule", ""},
+		{"other", ""},
+	}
+
+	check := func() {
+		for _, ti := range tlist {
+			out, err := guessImportPath(filepath.Join(tmpDir, ti.dir))
+			if ti.out == "" {
+				if _, ok := err.(ErrImportPath); !ok {
+					t.Errorf("%s: expected ErrImportPath, got %q, %v", ti.dir, out, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", ti.dir, err)
+			}
+			if out != ti.out {
+				t.Errorf("%s: expected %q, got %q", ti.dir, ti.out, out)
+			}
+		}
+	}
+	check()
+
+	// in a workspace only the modules it uses are allowed
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "root/go.work"), []byte("go 1.18\n\nuse (\n\t./nested // comment\n)\n"), 0644))
+	tlist[0].out, tlist[1].out, tlist[4].out = "", "", ""
+	check()
+
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "root/go.work"), []byte("go 1.18\n\nuse .\nuse \"./nested\"\n"), 0644))
+	tlist[0].out, tlist[1].out, tlist[4].out = "example.com/root", "example.com/root/a/b", "github.com/x/y"
+	check()
+
+	// GOWORK=off disables workspace mode, so the go.work file is ignored
+	must(ioutil.WriteFile(filepath.Join(tmpDir, "root/go.work"), []byte("go 1.18\n"), 0644))
+	must(os.Setenv("GOWORK", "off"))
+	defer os.Unsetenv("GOWORK")
+	check()
+
+	_, err = New().SetDir(filepath.Join(tmpDir, "other")).Render()
+	if !strings.Contains(fmt.Sprint(err), "-p") {
+		t.Errorf("expected error from Render suggesting -p, got %v", err)
+	}
+
+}
+
 func must(err error) {
 	if err != nil {
 		panic(err)
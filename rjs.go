@@ -3,6 +3,7 @@ package vgrouter
 import (
 	"errors"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/vugu/vugu/js"
@@ -16,7 +17,7 @@ func (r *Router) pushPathAndQuery(pathAndQuery string) {
 		if r.useFragment {
 			pqv = "#" + pathAndQuery
 		}
-		g.Get("window").Get("history").Call("pushState", nil, "", pqv)
+		g.Get("window").Get("history").Call("pushState", r.historyState(), "", pqv)
 	}
 
 }
@@ -29,11 +30,80 @@ func (r *Router) replacePathAndQuery(pathAndQuery string) {
 		if r.useFragment {
 			pqv = "#" + pathAndQuery
 		}
-		g.Get("window").Get("history").Call("replaceState", nil, "", pqv)
+		g.Get("window").Get("history").Call("replaceState", r.historyState(), "", pqv)
 	}
 
 }
 
+// replaceBrowserURL replaces the entire URL in the browser, without regard to fragment mode.
+func (r *Router) replaceBrowserURL(u string) {
+
+	g := js.Global()
+	if g.Truthy() {
+		h := g.Get("window").Get("history")
+		h.Call("replaceState", h.Get("state"), "", u)
+	}
+
+}
+
+// readBrowserLocation returns the full URL from the browser, without regard to fragment mode.
+func (r *Router) readBrowserLocation() (*url.URL, error) {
+
+	g := js.Global()
+	if !g.Truthy() {
+		return nil, errors.New("not in browser (js) environment")
+	}
+
+	return url.Parse(g.Get("window").Get("location").Call("toString").String())
+}
+
+// historyState returns the state object stored with each history entry so we can tell
+// where we are in the history when the user navigates.
+func (r *Router) historyState() interface{} {
+	return map[string]interface{}{"vgrouterIndex": r.histIdx}
+}
+
+// historyIndexFromState returns the history index from a state object created by historyState.
+// If state was not created by it, e.g. it is null for in-page anchors and entries from before the
+// router was loaded, ok is false.
+func historyIndexFromState(state js.Value) (idx int, ok bool) {
+	if !state.Truthy() {
+		return 0, false
+	}
+	v := state.Get("vgrouterIndex")
+	if v.Type() != js.TypeNumber {
+		return 0, false
+	}
+	return v.Int(), true
+}
+
+// readHistoryIndex returns the history index of the current browser history entry.
+// If not in the browser ok is false.
+func (r *Router) readHistoryIndex() (idx int, ok bool) {
+
+	g := js.Global()
+	if !g.Truthy() {
+		return 0, false
+	}
+
+	// an entry without our state is where our history starts
+	idx, _ = historyIndexFromState(g.Get("window").Get("history").Get("state"))
+	return idx, true
+}
+
+// historyGo calls window.history.go(n) and returns true, or returns false if not in browser.
+func (r *Router) historyGo(n int) bool {
+
+	g := js.Global()
+	if !g.Truthy() {
+		return false
+	}
+
+	g.Get("window").Get("history").Call("go", n)
+
+	return true
+}
+
 func (r *Router) readBrowserURL() (*url.URL, error) {
 
 	g := js.Global()
@@ -96,3 +166,112 @@ func (r *Router) addPopStateListener(f func(this js.Value, args []js.Value) inte
 	return nil
 
 }
+
+func (r *Router) removeClickListener() error {
+
+	g := js.Global()
+	if !g.Truthy() {
+		return errors.New("not in browser (js) environment")
+	}
+
+	if r.clickFunc.IsUndefined() {
+		return errors.New("click listener not set")
+	}
+
+	g.Get("document").Call("removeEventListener", "click", r.clickFunc)
+
+	r.clickFunc.Release()
+	r.clickFunc = js.Func{}
+
+	return nil
+}
+
+func (r *Router) addClickListener(f func(this js.Value, args []js.Value) interface{}) error {
+
+	g := js.Global()
+	if !g.Truthy() {
+		return errors.New("not in browser (js) environment")
+	}
+
+	if !r.clickFunc.IsUndefined() {
+		return errors.New("click listener already set")
+	}
+
+	jf := js.FuncOf(f)
+
+	g.Get("document").Call("addEventListener", "click", jf)
+
+	r.clickFunc = jf
+
+	return nil
+
+}
+
+// headAttr marks the tags in the document head created by applyHead, only these are changed by it.
+const headAttr = "data-vgrouter"
+
+// applyHead sets the document title and meta tags from h, removing the tags it created for
+// a previous Head which h does not have.  The title is restored to the original one if h has none.
+// Nothing is done until a non-empty Head is applied, so documents of apps which don't use
+// Heads are left alone.
+func (r *Router) applyHead(h Head) {
+
+	g := js.Global()
+	if !g.Truthy() {
+		return
+	}
+
+	if !r.headApplied {
+		if h.isZero() {
+			return
+		}
+		r.headApplied = true
+		r.origTitle = g.Get("document").Get("title").String()
+	}
+
+	doc := g.Get("document")
+	title := h.Title
+	if title == "" {
+		title = r.origTitle
+	}
+	doc.Set("title", title)
+
+	setHeadTag(doc, "meta", "name", "description", "content", h.Description)
+	setHeadTag(doc, "link", "rel", "canonical", "href", h.Canonical)
+
+	names := h.metaNames()
+	for _, k := range r.appliedMeta {
+		if _, ok := h.Meta[k]; !ok {
+			setHeadTag(doc, "meta", "name", k, "content", "")
+		}
+	}
+	for _, k := range names {
+		setHeadTag(doc, "meta", "name", k, "content", h.Meta[k])
+	}
+	r.appliedMeta = names
+
+}
+
+// setHeadTag sets the valueAttr of the tag created by applyHead in the document head whose keyAttr
+// is key, creating it if needed, or removes the tag if value is empty.  Tags which were already in
+// the document (without headAttr) are not changed.
+func setHeadTag(doc js.Value, tag, keyAttr, key, valueAttr, value string) {
+
+	el := doc.Call("querySelector", tag+"["+keyAttr+"="+strconv.Quote(key)+"]["+headAttr+"]")
+
+	if value == "" {
+		if el.Truthy() {
+			el.Call("remove")
+		}
+		return
+	}
+
+	if !el.Truthy() {
+		el = doc.Call("createElement", tag)
+		el.Call("setAttribute", keyAttr, key)
+		el.Call("setAttribute", headAttr, "")
+		doc.Get("head").Call("appendChild", el)
+	}
+	el.Call("setAttribute", valueAttr, value)
+
+}
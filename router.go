@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/vugu/vugu/js"
 )
@@ -16,9 +18,6 @@ import (
 // * need a method to just say "process this" and a variation of that which accepts an http.Request and sets it on the RouteMatch
 // * implement js stuff and fragment
 // * do tests in wasm test suite
-//   - one test can cover with and without fragement by detecting "#" upon page load
-// * do the change to generate to _gen.go, allow MixedCase.vugu, and put in a banner
-//   at the top of the generated file and detect before clobbering it
 // * make codegen directory router
 //   also make it output a list of files, so static generator can use it
 //   need index functionanlity plus see what we do about parameters if we can support
@@ -44,6 +43,8 @@ func New(eventEnv EventEnv) *Router {
 	return &Router{
 		eventEnv:     eventEnv,
 		bindParamMap: make(map[string]BindParam),
+		nameMap:      make(map[string]mpath),
+		prefetchTTL:  DefaultPrefetchTTL,
 	}
 }
 
@@ -51,22 +52,60 @@ func New(eventEnv EventEnv) *Router {
 type Router struct {
 	useFragment bool
 	pathPrefix  string
+	autoMigrate bool
 
 	popStateFunc js.Func
+	clickFunc    js.Func
 
 	eventEnv EventEnv
 
 	rlist           []routeEntry
 	notFoundHandler RouteHandler
+	loadHandler     RouteHandler         // called while routes added with AddRouteLoader are loading or failed
+	nameMap         map[string]mpath     // route names registered with NameRoute
+	metaMap         map[string]RouteMeta // route metadata registered with SetRouteMeta, keyed by mpath.String()
+
+	resolvers     map[string]RouteResolver  // resolvers added with AddResolver, keyed by mpath.String()
+	prefetchMu    sync.Mutex                // protects prefetchCache, which resolver goroutines update
+	prefetchCache map[string]*prefetchEntry // resolver results, see prefetchKey
+	prefetchTTL   time.Duration             // how long resolver results are used for
+	now           func() time.Time          // clock for prefetchTTL, time.Now if nil
+	processSeq    int                       // incremented each time a path is processed, except by reprocess
+
+	headMap     map[string]HeadFunc // head funcs set with SetRouteHeadFunc, keyed by mpath.String()
+	defaultHead Head                // set with SetDefaultHead
+	curHead     Head                // head for curPath
+	appliedMeta []string            // names of the meta tags from Head.Meta applied to the document
+	headApplied bool                // a non-empty Head has been applied to the document, see applyHead
+	origTitle   string              // document title before headApplied
+
+	locales    []string                     // set with SetLocales
+	locale     string                       // current locale
+	segmentMap map[string]map[string]string // translated path segments by locale, see SetSegmentTranslations
+
+	curPath    string       // path most recently processed
+	curQuery   url.Values   // query most recently processed
+	curMatches []RouteMatch // routes matched by curPath, in the order they were added
+	prevPath   string       // path processed before curPath
+	prevQuery  url.Values   // query processed before curPath
+
+	hist    []historyEntry // entries pushed or replaced by this router
+	histIdx int            // index of the current entry in hist (and window.history state in the browser)
 
 	// bindRoutePath string // the route (with :param stuff in it) that matches the bind params, so we can reconstruct it
 	bindRouteMPath mpath
 	bindParamMap   map[string]BindParam
 }
 
+type historyEntry struct {
+	path  string
+	query url.Values
+}
+
 type routeEntry struct {
 	mpath mpath
 	rh    RouteHandler
+	load  *routeLoad // set for routes added with AddRouteLoader
 }
 
 // SetUseFragment sets the fragment flag which if set means the fragment part of the URL (after the "#")
@@ -87,6 +126,18 @@ func (r *Router) SetPathPrefix(pfx string) {
 	r.pathPrefix = pfx
 }
 
+// SetAutoMigrate enables migration of URLs between fragment and path form during Pull.
+// If set and the browser URL is in the other form (e.g. a bookmarked /#/a while path mode is in use,
+// or /a while SetUseFragment(true) is in effect), Pull will rewrite it into the canonical form
+// using window.history.replaceState() and then route normally.  This is useful for applications
+// which move from fragment to path routing (or the reverse) and need old URLs to keep working.
+// When migrating to fragment form the document is assumed to be at the path prefix, so an app
+// served from a subdirectory should set it with SetPathPrefix.
+// This option is disabled by default.
+func (r *Router) SetAutoMigrate(v bool) {
+	r.autoMigrate = v
+}
+
 // ListenForPopState registers an event listener so the user navigating with
 // forward/back/history or fragment changes will be detected and handled by this router.
 // Any call to SetUseFragment or SetPathPrefix should occur before calling
@@ -119,8 +170,15 @@ func (r *Router) ListenForPopState() error {
 
 		// log.Printf("addPopStateListener calling process: tp=%q, q=%#v", tp, q)
 
+		idx, idxOK := 0, false
+		if len(args) > 0 {
+			idx, idxOK = historyIndexFromState(args[0].Get("state"))
+		}
+
 		r.eventEnv.Lock()
 		defer r.eventEnv.UnlockRender()
+		r.locale, tp = r.delocalize(tp)
+		r.setHistoryIndex(idx, idxOK, tp, q)
 		r.process(tp, q)
 
 		return nil
@@ -133,6 +191,162 @@ func (r *Router) UnlistenForPopState() error {
 	return r.removePopStateListener()
 }
 
+// ListenForLinkClicks registers a click event listener so clicks on regular <a href="..."> tags
+// which point to a path within this application are handled by this router with Navigate instead of
+// causing a page load.  Links to other origins or outside of the path prefix, clicks with modifier keys
+// or a non-primary button, and links with a target (other than "_self"), a download attribute or
+// rel="external" are left to the browser.  In fragment mode only links to a route in the fragment
+// of the current document (e.g. href="#/a") are handled, so links to other documents still load them.
+// Any call to SetUseFragment or SetPathPrefix should occur before calling
+// ListenForLinkClicks.
+//
+// Only works in wasm environment and if called outside it will have no effect and return error.
+func (r *Router) ListenForLinkClicks() error {
+	return r.addClickListener(func(this js.Value, args []js.Value) interface{} {
+
+		if len(args) == 0 {
+			return nil
+		}
+		ev := args[0]
+
+		if ev.Get("defaultPrevented").Truthy() ||
+			ev.Get("button").Int() != 0 ||
+			ev.Get("metaKey").Truthy() ||
+			ev.Get("ctrlKey").Truthy() ||
+			ev.Get("shiftKey").Truthy() ||
+			ev.Get("altKey").Truthy() {
+			return nil
+		}
+
+		a := ev.Get("target")
+		if !a.Truthy() || !a.Get("closest").Truthy() {
+			return nil
+		}
+		a = a.Call("closest", "a")
+		if !a.Truthy() {
+			return nil
+		}
+
+		// SVG links have a non-string href and are left alone
+		if !a.Call("hasAttribute", "href").Bool() || a.Get("href").Type() != js.TypeString {
+			return nil
+		}
+		if t := a.Call("getAttribute", "target"); t.Truthy() && t.String() != "_self" {
+			return nil
+		}
+		if a.Call("hasAttribute", "download").Bool() {
+			return nil
+		}
+		if rel := a.Call("getAttribute", "rel"); rel.Truthy() {
+			for _, v := range strings.Fields(rel.String()) {
+				if strings.EqualFold(v, "external") {
+					return nil
+				}
+			}
+		}
+
+		u, err := url.Parse(a.Get("href").String())
+		if err != nil {
+			log.Printf("ListenForLinkClicks: error parsing href: %v", err)
+			return nil
+		}
+		loc, err := r.readBrowserLocation()
+		if err != nil {
+			log.Printf("ListenForLinkClicks: error from readBrowserLocation: %v", err)
+			return nil
+		}
+
+		p, q, ok := r.linkPathQuery(u, loc)
+		if !ok {
+			return nil
+		}
+
+		ev.Call("preventDefault")
+
+		r.eventEnv.Lock()
+		defer r.eventEnv.UnlockRender()
+		r.locale, p = r.delocalize(p)
+		err = r.Navigate(p, q)
+		if err != nil {
+			log.Printf("ListenForLinkClicks: error from Navigate: %v", err)
+		}
+
+		return nil
+	})
+}
+
+// UnlistenForLinkClicks removes the listener created by ListenForLinkClicks.
+func (r *Router) UnlistenForLinkClicks() error {
+	return r.removeClickListener()
+}
+
+// sameDocRoot returns true if a and b are both the document root of the same directory,
+// e.g. "/app/" and "/app/index.html".
+func sameDocRoot(a, b string) bool {
+	dir := func(p string) (string, bool) {
+		if p == "" || strings.HasSuffix(p, "/") {
+			return p, true
+		}
+		i := strings.LastIndex(p, "/")
+		return p[:i+1], p[i+1:] == "index.html"
+	}
+	da, oka := dir(a)
+	db, okb := dir(b)
+	return oka && okb && da == db
+}
+
+// linkPathQuery returns the path (with prefix removed) and query that should be navigated to
+// when a link to u is clicked on the page at loc, or false if the browser should handle it.
+func (r *Router) linkPathQuery(u, loc *url.URL) (string, url.Values, bool) {
+
+	if u.Scheme != loc.Scheme || u.Host != loc.Host {
+		return "", nil, false
+	}
+
+	samePage := u.Path == loc.Path && u.RawQuery == loc.RawQuery
+
+	if r.useFragment {
+		// only links to a route in the fragment of this document are ours, e.g. "#/a"
+		if !samePage && !(u.RawQuery == loc.RawQuery && sameDocRoot(u.Path, loc.Path)) {
+			return "", nil, false
+		}
+		if !strings.HasPrefix(u.Fragment, "/") {
+			return "", nil, false
+		}
+		fu, err := url.Parse(escapedFragment(u))
+		if err != nil {
+			return "", nil, false
+		}
+		u = fu
+	} else if samePage && u.Fragment != "" {
+		// in-page anchor
+		return "", nil, false
+	}
+
+	p, ok := r.trimPathPrefix(u.Path)
+	if !ok {
+		return "", nil, false
+	}
+
+	return p, u.Query(), true
+}
+
+// trimPathPrefix returns p without the path prefix, or false if p does not start with it
+// followed by a slash or end, e.g. "/pfxother" with the prefix "/pfx".
+func (r *Router) trimPathPrefix(p string) (string, bool) {
+	if !strings.HasPrefix(p, r.pathPrefix) {
+		return "", false
+	}
+	p = strings.TrimPrefix(p, r.pathPrefix)
+	if p == "" {
+		p = "/"
+	}
+	if !strings.HasPrefix(p, "/") {
+		return "", false
+	}
+	return p, true
+}
+
 // MustNavigate is like Navigate but panics upon error.
 func (r *Router) MustNavigate(path string, query url.Values, opts ...NavigatorOpt) {
 	err := r.Navigate(path, query, opts...)
@@ -141,26 +355,236 @@ func (r *Router) MustNavigate(path string, query url.Values, opts ...NavigatorOp
 	}
 }
 
-// Navigate will go the specified path and query.
+// Navigate will go the specified path and query.  The path is not URL escaped, e.g. "/user/a b"
+// rather than "/user/a%20b", the same as paths read from the browser, and it is escaped when
+// written to the browser history (as it is by Href).  Earlier versions wrote the path as given,
+// so callers which pass already escaped paths must now pass them unescaped, otherwise they are
+// escaped twice (e.g. "/a%20b" becomes "/a%2520b").
 func (r *Router) Navigate(path string, query url.Values, opts ...NavigatorOpt) error {
 
 	r.process(path, query)
 
-	pq := r.pathPrefix + path
+	pq := r.prefixPathQuery(path, query)
+
+	replace := navOpts(opts).has(NavReplace)
+	r.recordHistory(path, query, replace)
+	if replace {
+		r.replacePathAndQuery(pq)
+	} else {
+		r.pushPathAndQuery(pq)
+	}
+
+	return nil
+}
+
+// Back is the same as Go(-1).
+func (r *Router) Back() {
+	r.Go(-1)
+}
+
+// Forward is the same as Go(1).
+func (r *Router) Forward() {
+	r.Go(1)
+}
+
+// Go moves n entries through the history, negative values go back and positive values go forward.
+// In the browser this calls window.history.go() and the resulting navigation is handled by the
+// listener added with ListenForPopState.  Outside the browser an in-memory history of the paths
+// passed to Navigate and Push is used and the path moved to is processed immediately.
+// Moving beyond either end of the history has no effect.
+func (r *Router) Go(n int) {
+
+	if r.historyGo(n) {
+		return
+	}
+
+	i := r.histIdx + n
+	if n == 0 || i < 0 || i >= len(r.hist) {
+		return
+	}
+
+	r.histIdx = i
+	e := r.hist[i]
+	r.process(e.path, e.query)
+}
+
+// CanGoBack returns true if there is a previous history entry which was created by this router,
+// i.e. Back will stay within the application.
+func (r *Router) CanGoBack() bool {
+	return r.histIdx > 0
+}
+
+// BackOr goes back if CanGoBack is true, otherwise it navigates to path and query.
+// This is useful for "back" buttons on pages which may have been loaded directly.
+func (r *Router) BackOr(path string, query url.Values, opts ...NavigatorOpt) error {
+	if r.CanGoBack() {
+		r.Back()
+		return nil
+	}
+	return r.Navigate(path, query, opts...)
+}
+
+// recordHistory adds (or replaces) the current entry in hist.
+func (r *Router) recordHistory(path string, query url.Values, replace bool) {
+
+	e := historyEntry{path: path, query: query}
+
+	if len(r.hist) == 0 {
+		idx, ok := r.readHistoryIndex()
+		if !ok && !replace { // no browser, this is simply the first entry
+			r.hist = append(r.hist, e)
+			r.histIdx = 0
+			return
+		}
+		// pick up from wherever the browser history is, e.g. after a page reload
+		r.hist = make([]historyEntry, idx+1)
+		r.histIdx = idx
+	}
+
+	if replace {
+		r.hist[r.histIdx] = e
+		return
+	}
+
+	r.hist = append(r.hist[:r.histIdx+1], e)
+	r.histIdx++
+}
+
+// setHistoryIndex is called when the browser moves to a different history entry.  If the index
+// of the entry is not known (ok is false), e.g. for an in-page anchor, the current index is kept.
+func (r *Router) setHistoryIndex(idx int, ok bool, path string, query url.Values) {
+	if !ok {
+		idx = r.histIdx
+	}
+	for len(r.hist) <= idx {
+		r.hist = append(r.hist, historyEntry{})
+	}
+	r.histIdx = idx
+	r.hist[idx] = historyEntry{path: path, query: query}
+}
+
+// prefixPathQuery returns the path with the path prefix and current locale prepended, URL escaped,
+// and the encoded query appended.  Paths within the router are not escaped, in the same way as
+// url.URL.Path, so e.g. "/a b" gives "/a%20b".
+func (r *Router) prefixPathQuery(path string, query url.Values) string {
+	return r.localePathQuery(r.locale, path, query)
+}
+
+// localePathQuery is like prefixPathQuery but for locale instead of the current locale.
+func (r *Router) localePathQuery(locale, path string, query url.Values) string {
+	pq := (&url.URL{Path: r.pathPrefix + r.localizePath(locale, path)}).EscapedPath()
 	q := query.Encode()
 	if len(q) > 0 {
 		pq = pq + "?" + q
 	}
+	return pq
+}
 
-	if navOpts(opts).has(NavReplace) {
-		r.replacePathAndQuery(pq)
-	} else {
-		r.pushPathAndQuery(pq)
+// Href returns the value to use for the href attribute of a link to path and query,
+// taking into account the path prefix and fragment mode.  The path is URL escaped, see Navigate.
+func (r *Router) Href(path string, query url.Values) string {
+	pq := r.prefixPathQuery(path, query)
+	if r.useFragment {
+		return "#" + pq
+	}
+	return pq
+}
+
+// MustNameRoute is like NameRoute but panics upon error.
+func (r *Router) MustNameRoute(name, path string) {
+	err := r.NameRoute(name, path)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NameRoute assigns a name to a route path (with params as :param) so links can refer
+// to it by name using URLFor instead of repeating the path.  Later calls with the same name
+// replace earlier ones.
+func (r *Router) NameRoute(name, path string) error {
+
+	mp, err := parseMpath(path)
+	if err != nil {
+		return err
+	}
+
+	if r.nameMap == nil {
+		r.nameMap = make(map[string]mpath)
+	}
+	r.nameMap[name] = mp
+
+	return nil
+}
+
+// ErrRouteNameNotFound is returned when a route name was not registered with NameRoute.
+type ErrRouteNameNotFound struct {
+	Name string // the route name
+}
+
+// Error implements error.
+func (e ErrRouteNameNotFound) Error() string { return fmt.Sprintf("route name %q not found", e.Name) }
+
+// pathFor returns the path for the named route with params merged into it and any params
+// not used in the path returned as query values.
+func (r *Router) pathFor(name string, params url.Values) (string, url.Values, error) {
+	mp, ok := r.nameMap[name]
+	if !ok {
+		return "", nil, ErrRouteNameNotFound{Name: name}
 	}
+	return mp.merge(params)
+}
+
+// URLFor returns the href for the route registered with NameRoute, with params filled into
+// the path and any remaining params used as the query string.  The result is suitable for
+// use as the href attribute of a link (see Href).
+func (r *Router) URLFor(name string, params url.Values) (string, error) {
+	p, q, err := r.pathFor(name, params)
+	if err != nil {
+		return "", err
+	}
+	return r.Href(p, q), nil
+}
+
+// RouteMeta is metadata about a route, e.g. "title" or "role".  Keys and their meaning are up to
+// the application.  Since it is a map, looking up a key works even if there is no metadata.
+type RouteMeta map[string]string
+
+// MustSetRouteMeta is like SetRouteMeta but panics upon error.
+func (r *Router) MustSetRouteMeta(path string, meta RouteMeta) {
+	err := r.SetRouteMeta(path, meta)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SetRouteMeta sets the metadata for a route path (with params as :param), which will be
+// provided as RouteMatch.Meta to the handlers of routes with the same path.  This allows
+// things like title management and access checks to be driven by data instead of code.
+// Metadata generated by rgen is set this way by RegisterRoutes.
+func (r *Router) SetRouteMeta(path string, meta RouteMeta) error {
+
+	mp, err := parseMpath(path)
+	if err != nil {
+		return err
+	}
+
+	if r.metaMap == nil {
+		r.metaMap = make(map[string]RouteMeta)
+	}
+	r.metaMap[mp.String()] = meta
 
 	return nil
 }
 
+// GetRouteMeta returns the metadata set with SetRouteMeta for a route path, or nil if none.
+func (r *Router) GetRouteMeta(path string) RouteMeta {
+	mp, err := parseMpath(path)
+	if err != nil {
+		return nil
+	}
+	return r.metaMap[mp.String()]
+}
+
 // BrowserAvail returns true if in browser mode.
 func (r *Router) BrowserAvail() bool {
 	// this is really just so otehr packages don't have to import `js` just to figure out if they should do extra browser setup
@@ -181,8 +605,19 @@ func (e ErrMissingPrefix) Error() string { return e.Message }
 // Only works in wasm environment otherwise has no effect and will return error.
 // If a path prefix has been set and the path read does not start with prefix
 // then an error of type *ErrMissingPrefix will be returned.
+// See SetAutoMigrate for converting URLs between fragment and path form before routing.
 func (r *Router) Pull() error {
 
+	if r.autoMigrate {
+		loc, err := r.readBrowserLocation()
+		if err != nil {
+			return err
+		}
+		if mu, ok := migrateURL(loc, r.useFragment, r.pathPrefix); ok {
+			r.replaceBrowserURL(mu)
+		}
+	}
+
 	u, err := r.readBrowserURL()
 	if err != nil {
 		return err
@@ -193,7 +628,10 @@ func (r *Router) Pull() error {
 		return ErrMissingPrefix{Path: p, Message: fmt.Sprintf("path %q does not begin with prefix %q", p, r.pathPrefix)}
 	}
 
-	r.process(strings.TrimPrefix(p, r.pathPrefix), u.Query())
+	tp, q := strings.TrimPrefix(p, r.pathPrefix), u.Query()
+	r.locale, tp = r.delocalize(tp)
+	r.recordHistory(tp, q, true)
+	r.process(tp, q)
 
 	return nil
 }
@@ -212,13 +650,11 @@ func (r *Router) Push(opts ...NavigatorOpt) error {
 		return err
 	}
 
-	q := outParams.Encode()
-	pq := r.pathPrefix + outPath
-	if len(q) > 0 {
-		pq = pq + "?" + q
-	}
+	pq := r.prefixPathQuery(outPath, outParams)
 
-	if navOpts(opts).has(NavReplace) {
+	replace := navOpts(opts).has(NavReplace)
+	r.recordHistory(outPath, outParams, replace)
+	if replace {
 		r.replacePathAndQuery(pq)
 	} else {
 		r.pushPathAndQuery(pq)
@@ -291,10 +727,19 @@ func (r *Router) GetNotFound() RouteHandler {
 }
 
 // ProcessRequest processes the route contained in request. This is meant for server-side use with static rendering.
+// The path prefix (see SetPathPrefix) is removed from the request path if it is followed by a slash or is the
+// whole path, and then the locale prefix (see SetLocales), so requests for the URLs used in the browser are
+// handled the same way.  Paths without the prefix are processed as they are.  Earlier versions did not remove
+// the prefix, so callers which remove it themselves no longer need to, and must not if their paths (without
+// the prefix) can themselves start with the prefix.
 func (r *Router) ProcessRequest(req *http.Request) {
 
 	p := req.URL.Path
 	q := req.URL.Query()
+	if tp, ok := r.trimPathPrefix(p); ok {
+		p = tp
+	}
+	r.locale, p = r.delocalize(p)
 
 	r.process2(p, q, req)
 
@@ -315,44 +760,46 @@ func (r *Router) process2(path string, query url.Values, req *http.Request) {
 		delete(r.bindParamMap, k)
 	}
 	r.bindRouteMPath = nil
-	foundExact := false
-
+	r.prevPath, r.prevQuery = r.curPath, r.curQuery
+	r.curPath, r.curQuery = path, query
+	r.curMatches = nil
+	r.processSeq++
+
+	// find all the matches first so the most specific exact one is known to the handlers
+	var rms []*RouteMatch
+	var rhs []RouteHandler
 	for _, re := range r.rlist {
 
-		pvals, exact, ok := re.mpath.match(path)
-		if !ok {
+		rm := r.matchRoute(re.mpath, path, query, req)
+		if rm == nil {
 			continue
 		}
 
-		if !foundExact && exact {
-			foundExact = true
-			r.bindRouteMPath = re.mpath
+		if res := r.resolvers[rm.RoutePath]; res != nil {
+			r.resolveData(rm, res)
 		}
 
-		// merge any other values from query into pvals
-		if pvals == nil {
-			pvals = make(url.Values)
-		}
-		for k, v := range query {
-			if pvals[k] == nil {
-				pvals[k] = v
-			}
-		}
+		rms = append(rms, rm)
+		rhs = append(rhs, re.rh)
+	}
 
-		req := &RouteMatch{
-			router:    r,
-			Path:      path,
-			RoutePath: re.mpath.String(),
-			Params:    pvals,
-			Exact:     exact,
-			Request:   req,
-		}
+	best := mostSpecificExact(rms)
+	if best >= 0 {
+		rms[best].selected = true
+		r.bindRouteMPath = rms[best].mpath
+	}
 
-		re.rh.RouteHandle(req)
+	for i, rm := range rms {
+		r.curMatches = append(r.curMatches, *rm)
+		rhs[i].RouteHandle(rm)
+	}
 
+	r.curHead = r.head(r.curMatches)
+	if req == nil {
+		r.applyHead(r.curHead)
 	}
 
-	if !foundExact && r.notFoundHandler != nil {
+	if best < 0 && r.notFoundHandler != nil {
 		r.notFoundHandler.RouteHandle(&RouteMatch{
 			router:  r,
 			Path:    path,
@@ -362,6 +809,124 @@ func (r *Router) process2(path string, query url.Values, req *http.Request) {
 
 }
 
+// matchRoute returns the RouteMatch for the route mp and path, or nil if it does not match.
+func (r *Router) matchRoute(mp mpath, path string, query url.Values, req *http.Request) *RouteMatch {
+
+	pvals, exact, ok := mp.match(path)
+	if !ok {
+		return nil
+	}
+
+	// merge any other values from query into pvals
+	if pvals == nil {
+		pvals = make(url.Values)
+	}
+	for k, v := range query {
+		if pvals[k] == nil {
+			pvals[k] = v
+		}
+	}
+
+	routePath := mp.String()
+	return &RouteMatch{
+		router:    r,
+		mpath:     mp,
+		Path:      path,
+		RoutePath: routePath,
+		Params:    pvals,
+		Exact:     exact,
+		Meta:      r.metaMap[routePath],
+		Locale:    r.locale,
+		Request:   req,
+	}
+}
+
+// CurrentPath returns the path most recently navigated to (without the path prefix).
+// Like other Router state it should only be accessed with the EventEnv lock held.
+func (r *Router) CurrentPath() string {
+	return r.curPath
+}
+
+// CurrentQuery returns the query most recently navigated to.  It must not be modified.
+func (r *Router) CurrentQuery() url.Values {
+	return r.curQuery
+}
+
+// CurrentParams returns the parameters (combined query and route params) of the most specific
+// exact route match for the current path, or just the query if there was no exact match.
+// It must not be modified.
+func (r *Router) CurrentParams() url.Values {
+	if rm, ok := r.CurrentExact(); ok {
+		return rm.Params
+	}
+	return r.curQuery
+}
+
+// CurrentMatches returns the routes matched by the current path, in the order the
+// routes were added.  This includes prefix (non-exact) matches.
+func (r *Router) CurrentMatches() []RouteMatch {
+	ret := make([]RouteMatch, len(r.curMatches))
+	copy(ret, r.curMatches)
+	return ret
+}
+
+// CurrentExact returns the most specific exact route match for the current path (see IsSelected).
+// If there was no exact match ok will be false.
+func (r *Router) CurrentExact() (rm RouteMatch, ok bool) {
+	for _, m := range r.curMatches {
+		if m.selected {
+			return m, true
+		}
+	}
+	return rm, false
+}
+
+// mostSpecificExact returns the index of the most specific exact match in rms, or -1 if none.
+// Comparing the route paths, static parts are more specific than params, which are more specific
+// than catch-all params.  For routes with the same path the one added first is used.
+func mostSpecificExact(rms []*RouteMatch) int {
+	ret := -1
+	for i, rm := range rms {
+		if rm.Exact && (ret < 0 || rm.mpath.compareSpecificity(rms[ret].mpath) > 0) {
+			ret = i
+		}
+	}
+	return ret
+}
+
+// PreviousPath returns the path that was navigated to before the current one.
+func (r *Router) PreviousPath() string {
+	return r.prevPath
+}
+
+// PreviousQuery returns the query that was navigated to before the current one.  It must not be modified.
+func (r *Router) PreviousQuery() url.Values {
+	return r.prevQuery
+}
+
+// IsActive returns true if the current path matches the route path pattern given,
+// either exactly or as a prefix.  E.g. with the current path "/a/1" the patterns "/",
+// "/a" and "/a/:id" are all active.
+func (r *Router) IsActive(pattern string) bool {
+	_, ok := r.matchCurrent(pattern)
+	return ok
+}
+
+// IsExactActive is like IsActive but only returns true if the pattern matches the current path exactly.
+func (r *Router) IsExactActive(pattern string) bool {
+	exact, ok := r.matchCurrent(pattern)
+	return ok && exact
+}
+
+func (r *Router) matchCurrent(pattern string) (exact, ok bool) {
+	mp, err := parseMpath(pattern)
+	if err != nil {
+		return false, false
+	}
+	_, exact, ok = mp.match(r.curPath)
+	return exact, ok
+}
+
 // RouteHandler implementations are called in response to a route matching (being navigated to).
 type RouteHandler interface {
 	RouteHandle(rm *RouteMatch)
@@ -379,10 +944,28 @@ type RouteMatch struct {
 	RoutePath string     // route path pattern with params as :param
 	Params    url.Values // parameters (combined query and route params)
 	Exact     bool       // true if the path is an exact match or false if just the prefix
+	Meta      RouteMeta  // metadata for RoutePath set with SetRouteMeta, nil if none
+	Locale    string     // current locale (see SetLocales), empty if none
+
+	Loading bool  // true if the route's handler (see AddRouteLoader) or data (see AddResolver) is being loaded
+	LoadErr error // error from the RouteLoader if loading failed
+
+	Data    interface{} // data from the route's resolver (see AddResolver), nil while Loading
+	DataErr error       // error from the route's resolver
 
 	Request *http.Request // if ProcessRequest is used, this will be set to Request instance passed to it; server-side only
 
-	router *Router
+	router   *Router
+	mpath    mpath
+	selected bool // the most specific exact match for the path
+}
+
+// IsSelected returns true if this is the most specific exact match for the path, i.e. the route
+// whose page should be shown.  E.g. for "/user/new" the route "/user/new" is selected rather than
+// "/user/:id" or "/user/*rest", regardless of the order they were added in.  Handlers of routes
+// which overlap like this can use it to do nothing when another route is selected.
+func (r *RouteMatch) IsSelected() bool {
+	return r.selected
 }
 
 // Bind adds a BindParam to the list of bound parameters.
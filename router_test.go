@@ -3,7 +3,9 @@ package vgrouter
 import (
 	"fmt"
 	"log"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
 )
 
@@ -161,3 +163,273 @@ func TestRouter(t *testing.T) {
 	}
 
 }
+
+func TestRouterLinkPathQuery(t *testing.T) {
+
+	type tcase struct {
+		href        string
+		loc         string
+		useFragment bool
+		pathPrefix  string
+		path        string
+		query       string
+		ok          bool
+	}
+
+	tclist := []tcase{
+		{"http://localhost/a", "http://localhost/", false, "", "/a", "", true},
+		{"http://localhost/a/b?p=1", "http://localhost/", false, "", "/a/b", "p=1", true},
+		{"http://localhost/", "http://localhost/a", false, "", "/", "", true},
+		{"http://example.com/a", "http://localhost/", false, "", "", "", false},
+		{"https://localhost/a", "http://localhost/", false, "", "", "", false},
+		{"http://localhost/a#top", "http://localhost/a", false, "", "", "", false},
+		{"http://localhost/b#top", "http://localhost/a", false, "", "/b", "", true},
+		{"http://localhost/pfx/a", "http://localhost/pfx/", false, "/pfx", "/a", "", true},
+		{"http://localhost/pfx", "http://localhost/pfx/a", false, "/pfx", "/", "", true},
+		{"http://localhost/other", "http://localhost/pfx/", false, "/pfx", "", "", false},
+		{"http://localhost/pfxother", "http://localhost/pfx/", false, "/pfx", "", "", false},
+		{"http://localhost/#/a?p=1", "http://localhost/#/", true, "", "/a", "p=1", true},
+		{"http://localhost/a", "http://localhost/#/", true, "", "", "", false},
+		{"http://localhost/other.html", "http://localhost/#/", true, "", "", "", false},
+		{"http://localhost/static/file.pdf", "http://localhost/app/#/", true, "", "", "", false},
+		{"http://localhost/index.html#/a", "http://localhost/#/", true, "", "/a", "", true},
+		{"http://localhost/app/#/a", "http://localhost/app/index.html#/b", true, "", "/a", "", true},
+		{"http://localhost/#/a", "http://localhost/app/#/b", true, "", "", "", false},
+		{"http://localhost/", "http://localhost/#/a", true, "", "", "", false},
+		{"http://localhost/#top", "http://localhost/#/", true, "", "", "", false},
+		{"http://localhost/#/pfx/a", "http://localhost/#/pfx/", true, "/pfx", "/a", "", true},
+	}
+
+	for i, tc := range tclist {
+		tc := tc
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+
+			r := New(nil)
+			r.SetUseFragment(tc.useFragment)
+			r.SetPathPrefix(tc.pathPrefix)
+
+			u, err := url.Parse(tc.href)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loc, err := url.Parse(tc.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, q, ok := r.linkPathQuery(u, loc)
+			if ok != tc.ok || p != tc.path || q.Encode() != tc.query {
+				t.Errorf("href=%q loc=%q: expected (%q, %q, %v), got (%q, %q, %v)",
+					tc.href, tc.loc, tc.path, tc.query, tc.ok, p, q.Encode(), ok)
+			}
+
+		})
+	}
+
+}
+
+func TestRouterEscapedParams(t *testing.T) {
+
+	r := New(nil)
+	r.SetPathPrefix("/app")
+	var got []string
+	r.MustAddRoute("/user/:id", RouteHandlerFunc(func(rm *RouteMatch) { got = append(got, rm.Params.Get("id")) }))
+
+	// as generated by rgen for URLUserId
+	urlUserID := func(id string) string { return "/user/" + id }
+
+	id := "a b%c"
+	r.MustNavigate(urlUserID(id), nil)
+
+	href := r.Href(urlUserID(id), nil)
+	if href != "/app/user/a%20b%25c" {
+		t.Errorf("unexpected href %q", href)
+	}
+
+	// clicking a link to href, and a request for it, give the same param
+	u, err := url.Parse("http://localhost" + href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := url.Parse("http://localhost/app/")
+	p, q, ok := r.linkPathQuery(u, loc)
+	if !ok {
+		t.Fatalf("link to %q not handled", href)
+	}
+	r.MustNavigate(p, q)
+	r.ProcessRequest(httptest.NewRequest("GET", href, nil))
+
+	if !reflect.DeepEqual(got, []string{id, id, id}) {
+		t.Errorf("expected param %q each time, got %q", id, got)
+	}
+
+}
+
+func TestRouterCurrent(t *testing.T) {
+
+	r := New(nil)
+	r.MustAddRoute("/", RouteHandlerFunc(func(rm *RouteMatch) {}))
+	r.MustAddRoute("/a", RouteHandlerFunc(func(rm *RouteMatch) {}))
+	r.MustAddRouteExact("/a/:id", RouteHandlerFunc(func(rm *RouteMatch) {}))
+	r.MustAddRoute("/b", RouteHandlerFunc(func(rm *RouteMatch) {}))
+
+	r.process("/b", nil)
+	r.process("/a/v1", url.Values{"p": {"1"}})
+
+	if r.CurrentPath() != "/a/v1" || r.CurrentQuery().Get("p") != "1" {
+		t.Errorf("unexpected current path/query: %q %#v", r.CurrentPath(), r.CurrentQuery())
+	}
+	if r.PreviousPath() != "/b" || r.PreviousQuery() != nil {
+		t.Errorf("unexpected previous path/query: %q %#v", r.PreviousPath(), r.PreviousQuery())
+	}
+
+	ms := r.CurrentMatches()
+	if len(ms) != 3 || ms[0].RoutePath != "/" || ms[1].RoutePath != "/a" || ms[2].RoutePath != "/a/:id" {
+		t.Errorf("unexpected matches: %#v", ms)
+	}
+
+	rm, ok := r.CurrentExact()
+	if !ok || rm.RoutePath != "/a/:id" {
+		t.Errorf("unexpected exact match: %#v", rm)
+	}
+	if r.CurrentParams().Get("id") != "v1" || r.CurrentParams().Get("p") != "1" {
+		t.Errorf("unexpected params: %#v", r.CurrentParams())
+	}
+
+	if !r.IsActive("/") || !r.IsActive("/a") || !r.IsActive("/a/:id") || r.IsActive("/b") {
+		t.Errorf("unexpected IsActive result")
+	}
+	if r.IsExactActive("/a") || !r.IsExactActive("/a/:id") {
+		t.Errorf("unexpected IsExactActive result")
+	}
+
+	r.process("/nothing", nil)
+	if _, ok := r.CurrentExact(); ok {
+		t.Errorf("unexpected exact match for /nothing")
+	}
+
+	// the most specific exact match is used regardless of the order routes were added in
+	r = New(nil)
+	selected := map[string]bool{}
+	h := RouteHandlerFunc(func(rm *RouteMatch) { selected[rm.RoutePath] = rm.IsSelected() })
+	r.MustAddRoute("/user/*rest", h)
+	r.MustAddRoute("/user/:id", h)
+	r.MustAddRoute("/user/new", h)
+	r.MustAddRoute("/user", h)
+	r.MustSetRouteMeta("/user/:id", RouteMeta{"title": "User"})
+	for _, tc := range []struct{ path, routePath string }{
+		{"/user/5", "/user/:id"},
+		{"/user/new", "/user/new"},
+		{"/user/5/edit", "/user/*rest"},
+		{"/user", "/user"},
+	} {
+		selected = map[string]bool{}
+		r.process(tc.path, nil)
+		rm, ok := r.CurrentExact()
+		if !ok || rm.RoutePath != tc.routePath || !selected[tc.routePath] {
+			t.Errorf("%s: expected %q to be selected, got %q (handlers %v)", tc.path, tc.routePath, rm.RoutePath, selected)
+		}
+		for rp, sel := range selected {
+			if sel && rp != tc.routePath {
+				t.Errorf("%s: unexpected selected route %q", tc.path, rp)
+			}
+		}
+	}
+	r.process("/user/5", nil)
+	if rm, _ := r.CurrentExact(); rm.Meta["title"] != "User" || r.CurrentParams().Get("id") != "5" {
+		t.Errorf("unexpected meta or params for most specific match: %#v", rm)
+	}
+
+}
+
+func TestRouterHistory(t *testing.T) {
+
+	var _ Navigator = New(nil)
+
+	r := New(nil)
+	var paths []string
+	r.MustAddRoute("/", RouteHandlerFunc(func(rm *RouteMatch) {
+		paths = append(paths, rm.Path)
+	}))
+
+	if r.CanGoBack() {
+		t.Errorf("CanGoBack should be false with no history")
+	}
+	r.Back() // no effect
+	if len(paths) != 0 {
+		t.Fatalf("unexpected paths: %#v", paths)
+	}
+
+	r.MustNavigate("/a", nil)
+	r.MustNavigate("/b", url.Values{"p": {"1"}})
+	r.MustNavigate("/c", nil)
+	r.MustNavigate("/d", nil, NavReplace)
+
+	r.Back()
+	if r.CurrentPath() != "/b" || r.CurrentQuery().Get("p") != "1" {
+		t.Errorf("unexpected current path after Back: %q %#v", r.CurrentPath(), r.CurrentQuery())
+	}
+	r.Forward()
+	if r.CurrentPath() != "/d" {
+		t.Errorf("unexpected current path after Forward: %q", r.CurrentPath())
+	}
+	r.Forward() // no effect
+	r.Go(-2)
+	if r.CurrentPath() != "/a" || r.CanGoBack() {
+		t.Errorf("unexpected state after Go(-2): %q %v", r.CurrentPath(), r.CanGoBack())
+	}
+
+	// navigating after going back discards the forward entries
+	r.MustNavigate("/e", nil)
+	r.Forward()
+	if r.CurrentPath() != "/e" {
+		t.Errorf("unexpected current path: %q", r.CurrentPath())
+	}
+
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(r.BackOr("/fallback", nil))
+	if r.CurrentPath() != "/a" {
+		t.Errorf("unexpected current path after BackOr: %q", r.CurrentPath())
+	}
+	must(r.BackOr("/fallback", nil))
+	if r.CurrentPath() != "/fallback" {
+		t.Errorf("unexpected current path after BackOr with no history: %q", r.CurrentPath())
+	}
+
+	// browser entries without our state (e.g. in-page anchors) keep the current index
+	r.setHistoryIndex(0, false, "/fallback", nil)
+	if !r.CanGoBack() || len(r.hist) != 2 {
+		t.Errorf("unexpected history after entry without index: %d %#v", r.histIdx, r.hist)
+	}
+	r.setHistoryIndex(0, true, "/a", nil)
+	if r.CanGoBack() || len(r.hist) != 2 {
+		t.Errorf("unexpected history after entry with index: %d %#v", r.histIdx, r.hist)
+	}
+
+}
+
+func TestRouterRouteMeta(t *testing.T) {
+
+	r := New(nil)
+
+	var rm *RouteMatch
+	r.MustAddRouteExact("/user/:id", RouteHandlerFunc(func(m *RouteMatch) { rm = m }))
+	r.MustSetRouteMeta("/user/:id/", RouteMeta{"title": "User"})
+
+	r.process("/user/1", nil)
+	if rm == nil || rm.Meta["title"] != "User" {
+		t.Errorf("expected title from route meta, got %#v", rm)
+	}
+
+	if r.GetRouteMeta("/user/:id")["title"] != "User" {
+		t.Errorf("GetRouteMeta failed")
+	}
+	if r.GetRouteMeta("/other") != nil {
+		t.Errorf("expected nil meta for route without any")
+	}
+
+}